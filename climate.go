@@ -0,0 +1,303 @@
+package weatherflow
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Layouts of ClimateSummary.Period for each kind of period.
+const (
+	DayLayout   = "2006-01-02"
+	MonthLayout = "2006-01"
+	YearLayout  = "2006"
+)
+
+// ClimateSummary summarises a device's observations over a local day, month
+// or year. Temperatures are in °C, speeds in m/s, rain in mm and pressures
+// in hPa.
+type ClimateSummary struct {
+	Period string    `json:"period"` // e.g. "2023-06-01", "2023-06" or "2023"
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+
+	// Observations is the number of observations received, and Expected the
+	// number the period would hold at the device's report interval. If the
+	// interval changes, the rest of the period is expected at the new one.
+	Observations int `json:"observations"`
+	Expected     int `json:"expected"`
+
+	// ReportInterval is the device's report interval, in minutes, at the
+	// latest observation.
+	ReportInterval int `json:"report_interval"`
+
+	MinTemp     *float64  `json:"min_temp,omitempty"`
+	MinTempTime time.Time `json:"min_temp_time"`
+	MaxTemp     *float64  `json:"max_temp,omitempty"`
+	MaxTempTime time.Time `json:"max_temp_time"`
+	MaxGust     float64   `json:"max_gust"`
+	MaxGustTime time.Time `json:"max_gust_time"`
+	MaxUV       float64   `json:"max_uv"`
+	Rain        float64   `json:"rain"`
+	RainMinutes int       `json:"rain_minutes"`
+
+	// PressureSum and PressureCount accumulate station pressure readings;
+	// see MeanPressure.
+	PressureSum   float64 `json:"pressure_sum"`
+	PressureCount int     `json:"pressure_count"`
+}
+
+// Coverage returns the percentage of the expected observations that were
+// received.
+func (s ClimateSummary) Coverage() float64 {
+	if s.Expected == 0 {
+		return 0
+	}
+	return 100 * float64(s.Observations) / float64(s.Expected)
+}
+
+// MeanPressure returns the mean station pressure over the period. ok is
+// false if there were no pressure readings.
+func (s ClimateSummary) MeanPressure() (mean float64, ok bool) {
+	if s.PressureCount == 0 {
+		return 0, false
+	}
+	return s.PressureSum / float64(s.PressureCount), true
+}
+
+// add folds an observation with the given report interval into s.
+func (s *ClimateSummary) add(obs ObsStData, interval time.Duration) {
+	t := obs.Time()
+
+	minutes := int(interval / time.Minute)
+	switch {
+	case s.Expected == 0:
+		s.Expected = int(s.End.Sub(s.Start) / interval)
+	case s.ReportInterval != 0 && s.ReportInterval != minutes:
+		rest := s.End.Sub(t)
+		s.Expected += int(rest/interval) - int(rest/(time.Duration(s.ReportInterval)*time.Minute))
+	}
+	s.ReportInterval = minutes
+	s.Observations++
+
+	if obs.AirTemperature != nil {
+		temp := *obs.AirTemperature
+		if s.MinTemp == nil || temp < *s.MinTemp {
+			s.MinTemp, s.MinTempTime = &temp, t
+		}
+		if s.MaxTemp == nil || temp > *s.MaxTemp {
+			s.MaxTemp, s.MaxTempTime = &temp, t
+		}
+	}
+	if obs.WindGust > s.MaxGust {
+		s.MaxGust, s.MaxGustTime = obs.WindGust, t
+	}
+	if obs.UV > s.MaxUV {
+		s.MaxUV = obs.UV
+	}
+	if obs.RainAccumulated > 0 {
+		s.Rain += obs.RainAccumulated
+		s.RainMinutes += int(interval / time.Minute)
+	}
+	if obs.StationPressure != nil {
+		s.PressureSum += *obs.StationPressure
+		s.PressureCount++
+	}
+}
+
+// ClimateAccumulator builds daily, monthly and yearly summaries of one
+// device's observations, split at local midnight in the station's time zone.
+// It can be saved and restored with MarshalJSON and UnmarshalJSON. It is
+// safe for concurrent use.
+type ClimateAccumulator struct {
+	loc       *time.Location
+	lastEpoch int
+	days      map[string]*ClimateSummary
+	months    map[string]*ClimateSummary
+	years     map[string]*ClimateSummary
+	mu        sync.Mutex
+}
+
+// NewClimateAccumulator creates a ClimateAccumulator for a station in loc.
+// A nil loc means UTC.
+func NewClimateAccumulator(loc *time.Location) *ClimateAccumulator {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return &ClimateAccumulator{
+		loc:    loc,
+		days:   make(map[string]*ClimateSummary),
+		months: make(map[string]*ClimateSummary),
+		years:  make(map[string]*ClimateSummary),
+	}
+}
+
+// Handle adds the observations in an obs_st message. Other messages are
+// ignored.
+func (a *ClimateAccumulator) Handle(msg Message) {
+	if m, ok := msg.(*MessageObsSt); ok {
+		for _, obs := range m.Obs {
+			a.Add(obs)
+		}
+	}
+}
+
+// Add folds an observation into the summaries of its day, month and year.
+// Observations no later than the latest one already added are ignored, so
+// that the server's cached observation isn't counted twice after a
+// restart.
+func (a *ClimateAccumulator) Add(obs ObsStData) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if obs.TimeEpoch <= a.lastEpoch {
+		return
+	}
+	a.lastEpoch = obs.TimeEpoch
+
+	interval := time.Duration(obs.ReportInterval) * time.Minute
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	t := obs.Time().In(a.loc)
+	y, m, d := t.Date()
+	dayStart, dayEnd := LocalDay(t, a.loc)
+	monthStart := time.Date(y, m, 1, 0, 0, 0, 0, a.loc)
+	yearStart := time.Date(y, 1, 1, 0, 0, 0, 0, a.loc)
+
+	for _, p := range []struct {
+		summaries  map[string]*ClimateSummary
+		layout     string
+		start, end time.Time
+	}{
+		{a.days, DayLayout, dayStart, dayEnd},
+		{a.months, MonthLayout, monthStart, monthStart.AddDate(0, 1, 0)},
+		{a.years, YearLayout, yearStart, yearStart.AddDate(1, 0, 0)},
+	} {
+		key := time.Date(y, m, d, 0, 0, 0, 0, a.loc).Format(p.layout)
+		s, ok := p.summaries[key]
+		if !ok {
+			s = &ClimateSummary{Period: key, Start: p.start, End: p.end}
+			p.summaries[key] = s
+		}
+		s.add(obs, interval)
+	}
+}
+
+// Day returns the summary of the local day containing t.
+func (a *ClimateAccumulator) Day(t time.Time) (ClimateSummary, bool) {
+	return a.get(a.days, t, DayLayout)
+}
+
+// Month returns the summary of the local month containing t.
+func (a *ClimateAccumulator) Month(t time.Time) (ClimateSummary, bool) {
+	return a.get(a.months, t, MonthLayout)
+}
+
+// Year returns the summary of the local year containing t.
+func (a *ClimateAccumulator) Year(t time.Time) (ClimateSummary, bool) {
+	return a.get(a.years, t, YearLayout)
+}
+
+func (a *ClimateAccumulator) get(summaries map[string]*ClimateSummary, t time.Time, layout string) (ClimateSummary, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s, ok := summaries[t.In(a.loc).Format(layout)]
+	if !ok {
+		return ClimateSummary{}, false
+	}
+	return *s, true
+}
+
+// Days returns the daily summaries in chronological order.
+func (a *ClimateAccumulator) Days() []ClimateSummary {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return sortedSummaries(a.days)
+}
+
+// Months returns the monthly summaries in chronological order.
+func (a *ClimateAccumulator) Months() []ClimateSummary {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return sortedSummaries(a.months)
+}
+
+// Years returns the yearly summaries in chronological order.
+func (a *ClimateAccumulator) Years() []ClimateSummary {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return sortedSummaries(a.years)
+}
+
+func sortedSummaries(summaries map[string]*ClimateSummary) []ClimateSummary {
+	out := make([]ClimateSummary, 0, len(summaries))
+	for _, s := range summaries {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+	return out
+}
+
+// climateAccumulatorJSON is the serialised form of a ClimateAccumulator.
+type climateAccumulatorJSON struct {
+	Location  string           `json:"location"`
+	Offset    int              `json:"offset"` // seconds east of UTC at LastEpoch
+	LastEpoch int              `json:"last_epoch"`
+	Days      []ClimateSummary `json:"days"`
+	Months    []ClimateSummary `json:"months"`
+	Years     []ClimateSummary `json:"years"`
+}
+
+func (a *ClimateAccumulator) MarshalJSON() ([]byte, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	_, offset := time.Unix(int64(a.lastEpoch), 0).In(a.loc).Zone()
+	return json.Marshal(climateAccumulatorJSON{
+		Location:  a.loc.String(),
+		Offset:    offset,
+		LastEpoch: a.lastEpoch,
+		Days:      sortedSummaries(a.days),
+		Months:    sortedSummaries(a.months),
+		Years:     sortedSummaries(a.years),
+	})
+}
+
+// UnmarshalJSON restores an accumulator saved with MarshalJSON, including
+// its time zone. A zone that can't be loaded by name, such as one made with
+// time.FixedZone, is restored as a fixed zone with the offset it had at the
+// latest observation.
+func (a *ClimateAccumulator) UnmarshalJSON(data []byte) error {
+	var v climateAccumulatorJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	loc, err := time.LoadLocation(v.Location)
+	if err != nil {
+		loc = time.FixedZone(v.Location, v.Offset)
+	}
+
+	index := func(summaries []ClimateSummary) map[string]*ClimateSummary {
+		m := make(map[string]*ClimateSummary, len(summaries))
+		for i := range summaries {
+			s := summaries[i]
+			s.Start, s.End = s.Start.In(loc), s.End.In(loc)
+			m[s.Period] = &s
+		}
+		return m
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.loc = loc
+	a.lastEpoch = v.LastEpoch
+	a.days = index(v.Days)
+	a.months = index(v.Months)
+	a.years = index(v.Years)
+	return nil
+}
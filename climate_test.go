@@ -0,0 +1,148 @@
+package weatherflow_test
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/tris/weatherflow"
+)
+
+func TestClimateAccumulator(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+
+	acc := weatherflow.NewClimateAccumulator(la)
+
+	// Twelve hours of one-minute observations from 6pm local on 10 March
+	// 2023, crossing local midnight, with the temperature falling a degree
+	// an hour, some rain before midnight and a gust after it.
+	start := time.Date(2023, 3, 10, 18, 0, 0, 0, la)
+	for i := 0; i < 720; i++ {
+		ts := start.Add(time.Duration(i) * time.Minute)
+		obs := weatherflow.ObsStData{
+			TimeEpoch:       int(ts.Unix()),
+			ReportInterval:  1,
+			AirTemperature:  float64Ptr(15 - float64(i)/60),
+			StationPressure: float64Ptr(1000 + float64(i%2)),
+			WindGust:        3,
+		}
+		if i >= 60 && i < 90 {
+			obs.RainAccumulated = 0.1
+		}
+		if i == 400 {
+			obs.WindGust = 14.5
+			obs.UV = 0.5
+		}
+		acc.Handle(&weatherflow.MessageObsSt{Obs: []weatherflow.ObsStData{obs}})
+	}
+
+	// Replays of old observations are ignored.
+	acc.Add(weatherflow.ObsStData{TimeEpoch: int(start.Unix()), AirTemperature: float64Ptr(40)})
+
+	day1, ok := acc.Day(time.Date(2023, 3, 10, 12, 0, 0, 0, la))
+	if !ok {
+		t.Fatal("Day(10 March): no summary")
+	}
+	if day1.Period != "2023-03-10" || day1.Observations != 360 || day1.Expected != 1440 || day1.Coverage() != 25 {
+		t.Errorf("10 March: period %q, %d of %d observations (%.1f%%); want 2023-03-10, 360 of 1440 (25%%)",
+			day1.Period, day1.Observations, day1.Expected, day1.Coverage())
+	}
+	if *day1.MaxTemp != 15 || !day1.MaxTempTime.Equal(start) || math.Abs(*day1.MinTemp-9.0167) > 0.001 {
+		t.Errorf("10 March: temperature %v at %v to %v; want 9.02 to 15 at %v", *day1.MinTemp, day1.MaxTempTime, *day1.MaxTemp, start)
+	}
+	if math.Abs(day1.Rain-3) > 1e-9 || day1.RainMinutes != 30 {
+		t.Errorf("10 March: %v mm of rain in %d minutes, want 3 mm in 30", day1.Rain, day1.RainMinutes)
+	}
+	if p, ok := day1.MeanPressure(); !ok || p != 1000.5 {
+		t.Errorf("10 March: mean pressure %v, %v; want 1000.5", p, ok)
+	}
+
+	// 12 March is 23 hours long; 11 March is normal.
+	day2, ok := acc.Day(time.Date(2023, 3, 11, 3, 0, 0, 0, la))
+	if !ok {
+		t.Fatal("Day(11 March): no summary")
+	}
+	if day2.Observations != 360 || day2.Expected != 1440 || day2.Rain != 0 || day2.MaxGust != 14.5 || day2.MaxUV != 0.5 {
+		t.Errorf("11 March: unexpected summary %+v", day2)
+	}
+	if want := start.Add(400 * time.Minute); !day2.MaxGustTime.Equal(want) {
+		t.Errorf("11 March: gust at %v, want %v", day2.MaxGustTime, want)
+	}
+	acc.Add(weatherflow.ObsStData{TimeEpoch: int(time.Date(2023, 3, 12, 12, 0, 0, 0, la).Unix()), ReportInterval: 1})
+	if day3, _ := acc.Day(time.Date(2023, 3, 12, 12, 0, 0, 0, la)); day3.Expected != 1380 {
+		t.Errorf("12 March: expected %d observations, want 1380", day3.Expected)
+	}
+
+	month, ok := acc.Month(start)
+	if !ok || month.Period != "2023-03" || month.Observations != 721 || month.Expected != 31*1440-60 || *month.MaxTemp != 15 || month.MaxGust != 14.5 {
+		t.Errorf("March: unexpected summary %+v", month)
+	}
+	if years := acc.Years(); len(years) != 1 || years[0].Period != "2023" || years[0].Expected != 365*1440 {
+		t.Errorf("Years() = %+v, want one 2023 summary", years)
+	}
+	if days := acc.Days(); len(days) != 3 || days[0].Period != "2023-03-10" || days[2].Period != "2023-03-12" {
+		t.Errorf("Days() returned %d summaries, want 10 to 12 March in order", len(days))
+	}
+	if _, ok := acc.Day(time.Date(2023, 4, 1, 0, 0, 0, 0, la)); ok {
+		t.Error("Day(1 April): expected no summary")
+	}
+
+	// Save, restore and carry on.
+	data, err := json.Marshal(acc)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	restored := weatherflow.NewClimateAccumulator(nil)
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	restored.Add(weatherflow.ObsStData{TimeEpoch: int(start.Unix()), AirTemperature: float64Ptr(40)}) // still a replay
+	restored.Add(weatherflow.ObsStData{TimeEpoch: int(time.Date(2023, 3, 12, 13, 0, 0, 0, la).Unix()), ReportInterval: 1, AirTemperature: float64Ptr(-2)})
+
+	day3, _ := restored.Day(time.Date(2023, 3, 12, 3, 0, 0, 0, la))
+	if day3.Observations != 2 || *day3.MinTemp != -2 || day3.Expected != 1380 {
+		t.Errorf("restored 12 March: %d of %d observations, minimum %v; want 2 of 1380, -2", day3.Observations, day3.Expected, *day3.MinTemp)
+	}
+	if month, _ := restored.Month(start); month.Observations != 722 || month.Start.Location().String() != "America/Los_Angeles" {
+		t.Errorf("restored March: %d observations starting %v; want 722 in America/Los_Angeles", month.Observations, month.Start)
+	}
+}
+
+func TestClimateAccumulatorFixedZone(t *testing.T) {
+	zone := time.FixedZone("Station time", 10*3600)
+	acc := weatherflow.NewClimateAccumulator(zone)
+
+	// One-minute observations for the first half of the day, then
+	// five-minute ones.
+	start := time.Date(2023, 6, 1, 0, 0, 0, 0, zone)
+	for i := 0; i < 720; i++ {
+		acc.Add(weatherflow.ObsStData{TimeEpoch: int(start.Add(time.Duration(i) * time.Minute).Unix()), ReportInterval: 1})
+	}
+	for i := 720; i < 1440; i += 5 {
+		acc.Add(weatherflow.ObsStData{TimeEpoch: int(start.Add(time.Duration(i) * time.Minute).Unix()), ReportInterval: 5})
+	}
+
+	day, _ := acc.Day(start)
+	if day.Observations != 864 || day.Expected != 864 || day.ReportInterval != 5 {
+		t.Errorf("%d of %d observations at %d minutes, want 864 of 864 at 5", day.Observations, day.Expected, day.ReportInterval)
+	}
+
+	data, err := json.Marshal(acc)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var restored weatherflow.ClimateAccumulator
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if day, ok := restored.Day(start); !ok || !day.Start.Equal(start) {
+		t.Errorf("restored day starts %v, %v; want %v", day.Start, ok, start)
+	}
+	if _, offset := restored.Days()[0].Start.Zone(); offset != 10*3600 {
+		t.Errorf("restored offset = %d, want %d", offset, 10*3600)
+	}
+}
@@ -0,0 +1,406 @@
+package weatherflow
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DegreeDayKind selects which degree days a DegreeDays accumulator counts.
+type DegreeDayKind int
+
+const (
+	GrowingDegreeDays DegreeDayKind = iota // above Base, capped at Cap
+	HeatingDegreeDays                      // below Base
+	CoolingDegreeDays                      // above Base
+)
+
+func (k DegreeDayKind) String() string {
+	switch k {
+	case GrowingDegreeDays:
+		return "growing"
+	case HeatingDegreeDays:
+		return "heating"
+	case CoolingDegreeDays:
+		return "cooling"
+	default:
+		return fmt.Sprintf("DegreeDayKind(%d)", int(k))
+	}
+}
+
+// DegreeDayMethod is a way of estimating degree days from a day's minimum
+// and maximum temperatures.
+type DegreeDayMethod int
+
+const (
+	// MethodAverage subtracts the base from the mean of the minimum and
+	// maximum, each first limited to the cap (McMaster & Wilhelm's method
+	// 1).
+	MethodAverage DegreeDayMethod = iota
+
+	// MethodModifiedAverage is MethodAverage with the minimum and maximum
+	// also raised to the base (McMaster & Wilhelm's method 2), as used for
+	// corn's 86/50 °F growing degree days.
+	MethodModifiedAverage
+
+	// MethodSingleSine fits a sine curve through the day's minimum and
+	// maximum and integrates it between the base and cap (Baskerville &
+	// Emin, 1969), with a horizontal cutoff at the cap.
+	MethodSingleSine
+
+	// MethodDoubleSine fits one sine curve from the day's minimum to its
+	// maximum and another from the maximum to the next day's minimum,
+	// integrating each over half a day.
+	MethodDoubleSine
+)
+
+func (m DegreeDayMethod) String() string {
+	switch m {
+	case MethodAverage:
+		return "average"
+	case MethodModifiedAverage:
+		return "modified average"
+	case MethodSingleSine:
+		return "single sine"
+	case MethodDoubleSine:
+		return "double sine"
+	default:
+		return fmt.Sprintf("DegreeDayMethod(%d)", int(m))
+	}
+}
+
+// SeasonStart is the day of the year on which an accumulator resets. The
+// zero value means 1 January.
+type SeasonStart struct {
+	Month time.Month
+	Day   int
+}
+
+// start returns the start of the season containing t.
+func (s SeasonStart) start(t time.Time) time.Time {
+	month, day := s.Month, s.Day
+	if month == 0 {
+		month = time.January
+	}
+	if day == 0 {
+		day = 1
+	}
+
+	start := time.Date(t.Year(), month, day, 0, 0, 0, 0, t.Location())
+	if start.After(t) {
+		start = start.AddDate(-1, 0, 0)
+	}
+	return start
+}
+
+// DegreeDayConfig configures a DegreeDays accumulator. Temperatures are in
+// °C, so the results are in °C·days (multiply by 1.8 for °F·days).
+type DegreeDayConfig struct {
+	Kind   DegreeDayKind
+	Method DegreeDayMethod
+
+	// Base is the lower threshold for growing and cooling degree days, and
+	// the upper threshold for heating degree days.
+	Base float64
+
+	// Cap is the upper threshold for growing degree days, above which
+	// development is assumed not to speed up. Zero means no cap.
+	Cap float64
+
+	// Season is when the accumulator resets.
+	Season SeasonStart
+
+	// Location is the time zone that defines days and the season. Nil means
+	// UTC.
+	Location *time.Location
+}
+
+// Day returns the degree days for a day with the given minimum and maximum
+// temperatures. nextMin, the following day's minimum, is only used by
+// MethodDoubleSine.
+func (cfg DegreeDayConfig) Day(min, max, nextMin float64) float64 {
+	lower, upper := cfg.Base, math.Inf(1)
+	if cfg.Kind == GrowingDegreeDays && cfg.Cap > cfg.Base {
+		upper = cfg.Cap
+	}
+
+	var above float64
+	switch cfg.Method {
+	case MethodSingleSine:
+		above = sineDegreeDays(min, max, lower, upper)
+	case MethodDoubleSine:
+		above = (sineDegreeDays(min, max, lower, upper) + sineDegreeDays(nextMin, max, lower, upper)) / 2
+	case MethodModifiedAverage:
+		above = math.Max(0, (clamp(min, lower, upper)+clamp(max, lower, upper))/2-lower)
+	default:
+		above = math.Max(0, (math.Min(min, upper)+math.Min(max, upper))/2-lower)
+	}
+
+	if cfg.Kind != HeatingDegreeDays {
+		return above
+	}
+
+	// Degree days below the base are the mean's shortfall plus the excess
+	// above it that the mean hides.
+	switch cfg.Method {
+	case MethodSingleSine, MethodDoubleSine:
+		mean := (min + max) / 2
+		if cfg.Method == MethodDoubleSine {
+			mean = (min + 2*max + nextMin) / 4
+		}
+		return cfg.Base - mean + above
+	default:
+		return math.Max(0, cfg.Base-(min+max)/2)
+	}
+}
+
+// sineDegreeDays integrates a sine curve between min and max over a day,
+// counting only the part between lower and upper (Baskerville & Emin, with a
+// horizontal cutoff).
+func sineDegreeDays(min, max, lower, upper float64) float64 {
+	if min > max {
+		min, max = max, min
+	}
+
+	switch {
+	case max <= lower:
+		return 0
+	case min >= upper:
+		return upper - lower
+	case min >= lower && max <= upper:
+		return (min+max)/2 - lower
+	}
+
+	mean, amp := (min+max)/2, (max-min)/2
+	theta1, theta2 := -math.Pi/2, math.Pi/2
+	if min < lower {
+		theta1 = math.Asin((lower - mean) / amp)
+	}
+	if max > upper {
+		theta2 = math.Asin((upper - mean) / amp)
+	}
+
+	dd := (mean-lower)*(theta2-theta1) + amp*(math.Cos(theta1)-math.Cos(theta2))
+	if max > upper {
+		dd += (upper - lower) * (math.Pi/2 - theta2)
+	}
+	return dd / math.Pi
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(v, hi))
+}
+
+// DegreeDays accumulates degree days over a season from one device's air
+// temperature. Each local day's minimum and maximum are taken from its
+// observations, and a day counts once an observation from a later day has
+// arrived. It is safe for concurrent use.
+type DegreeDays struct {
+	cfg    DegreeDayConfig
+	season time.Time
+	days   map[string]*dayRange
+	mu     sync.Mutex
+}
+
+// dayRange is the temperature range of a local day.
+type dayRange struct {
+	date     string
+	min, max float64
+}
+
+func NewDegreeDays(cfg DegreeDayConfig) *DegreeDays {
+	if cfg.Location == nil {
+		cfg.Location = time.UTC
+	}
+	return &DegreeDays{
+		cfg:  cfg,
+		days: make(map[string]*dayRange),
+	}
+}
+
+// Handle adds the observations in an obs_st message. Other messages are
+// ignored.
+func (d *DegreeDays) Handle(msg Message) {
+	if m, ok := msg.(*MessageObsSt); ok {
+		for _, obs := range m.Obs {
+			d.Add(obs)
+		}
+	}
+}
+
+// Add records an observation's air temperature, starting a new season if it
+// belongs to one.
+func (d *DegreeDays) Add(obs ObsStData) {
+	if obs.AirTemperature == nil {
+		return
+	}
+	t := obs.Time().In(d.cfg.Location)
+	temp := *obs.AirTemperature
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	season := d.cfg.Season.start(t)
+	if season.Before(d.season) {
+		return // from a previous season
+	}
+	if season.After(d.season) {
+		d.season = season
+		d.days = make(map[string]*dayRange)
+	}
+
+	date := t.Format(DayLayout)
+	r, ok := d.days[date]
+	if !ok {
+		d.days[date] = &dayRange{date: date, min: temp, max: temp}
+		return
+	}
+	r.min = math.Min(r.min, temp)
+	r.max = math.Max(r.max, temp)
+}
+
+// DailyDegreeDays is the degree days for one local day.
+type DailyDegreeDays struct {
+	Date       string  // e.g. "2023-06-01"
+	Min, Max   float64 // °C
+	DegreeDays float64
+}
+
+// Daily returns the degree days for each complete day of the current
+// season, in order.
+func (d *DegreeDays) Daily() []DailyDegreeDays {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	days := make([]*dayRange, 0, len(d.days))
+	for _, r := range d.days {
+		days = append(days, r)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].date < days[j].date })
+
+	var out []DailyDegreeDays
+	for i := 0; i+1 < len(days); i++ {
+		r, next := days[i], days[i+1]
+
+		// After a missing day, there's no next minimum to use.
+		nextMin := r.min
+		if date, err := time.Parse(DayLayout, r.date); err == nil && date.AddDate(0, 0, 1).Format(DayLayout) == next.date {
+			nextMin = next.min
+		}
+
+		out = append(out, DailyDegreeDays{
+			Date:       r.date,
+			Min:        r.min,
+			Max:        r.max,
+			DegreeDays: d.cfg.Day(r.min, r.max, nextMin),
+		})
+	}
+	return out
+}
+
+// Total returns the degree days accumulated over the complete days of the
+// current season.
+func (d *DegreeDays) Total() float64 {
+	total := 0.0
+	for _, day := range d.Daily() {
+		total += day.DegreeDays
+	}
+	return total
+}
+
+// Season returns the start of the current season.
+func (d *DegreeDays) Season() time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.season
+}
+
+// ChillConfig configures a ChillHours accumulator.
+type ChillConfig struct {
+	// Min and Max bound the temperatures, in °C, that count towards chill.
+	// The defaults, 0 and 7.2 °C (32 and 45 °F), are the "0–45 °F" model;
+	// set Min to -273.15 for the older "below 45 °F" model.
+	Min, Max float64
+
+	// Season is when the accumulator resets, typically in autumn.
+	Season SeasonStart
+
+	// Location is the time zone that defines the season. Nil means UTC.
+	Location *time.Location
+}
+
+// ChillHours accumulates the time one device's air temperature spends in
+// the chilling range over a season. Each observation counts for its report
+// interval. It is safe for concurrent use.
+type ChillHours struct {
+	cfg       ChillConfig
+	season    time.Time
+	hours     float64
+	lastEpoch int
+	mu        sync.Mutex
+}
+
+func NewChillHours(cfg ChillConfig) *ChillHours {
+	if cfg.Min == 0 && cfg.Max == 0 {
+		cfg.Max = 7.2
+	}
+	if cfg.Location == nil {
+		cfg.Location = time.UTC
+	}
+	return &ChillHours{cfg: cfg}
+}
+
+// Handle adds the observations in an obs_st message. Other messages are
+// ignored.
+func (c *ChillHours) Handle(msg Message) {
+	if m, ok := msg.(*MessageObsSt); ok {
+		for _, obs := range m.Obs {
+			c.Add(obs)
+		}
+	}
+}
+
+// Add records an observation, starting a new season if it belongs to one.
+// Observations no later than the latest one already added are ignored.
+func (c *ChillHours) Add(obs ObsStData) {
+	if obs.AirTemperature == nil {
+		return
+	}
+	interval := time.Duration(obs.ReportInterval) * time.Minute
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if obs.TimeEpoch <= c.lastEpoch {
+		return
+	}
+	c.lastEpoch = obs.TimeEpoch
+
+	if season := c.cfg.Season.start(obs.Time().In(c.cfg.Location)); season.After(c.season) {
+		c.season = season
+		c.hours = 0
+	}
+
+	if temp := *obs.AirTemperature; temp >= c.cfg.Min && temp <= c.cfg.Max {
+		c.hours += interval.Hours()
+	}
+}
+
+// Total returns the chill hours accumulated in the current season.
+func (c *ChillHours) Total() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hours
+}
+
+// Season returns the start of the current season.
+func (c *ChillHours) Season() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.season
+}
@@ -0,0 +1,246 @@
+package weatherflow_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/tris/weatherflow"
+)
+
+func TestDegreeDayMethods(t *testing.T) {
+	fToC := func(f float64) float64 { return (f - 32) * 5 / 9 }
+
+	tests := []struct {
+		name     string
+		cfg      weatherflow.DegreeDayConfig
+		min, max float64
+		nextMin  float64
+		want     float64
+	}{
+		// Corn growing degree days, 86/50 °F method: a 95/45 °F day gives
+		// 18 °F·days (e.g. Purdue Extension, "Heat Unit Concepts").
+		{
+			name: "corn GDD",
+			cfg:  weatherflow.DegreeDayConfig{Method: weatherflow.MethodModifiedAverage, Base: fToC(50), Cap: fToC(86)},
+			min:  fToC(45), max: fToC(95),
+			want: 18 / 1.8,
+		},
+		{
+			name: "average, no cap",
+			cfg:  weatherflow.DegreeDayConfig{Base: 10},
+			min:  10, max: 30,
+			want: 10,
+		},
+		{
+			name: "average, capped",
+			cfg:  weatherflow.DegreeDayConfig{Base: 10, Cap: 25},
+			min:  5, max: 30,
+			want: 5,
+		},
+		{
+			name: "average, cold day",
+			cfg:  weatherflow.DegreeDayConfig{Base: 10},
+			min:  -5, max: 15,
+			want: 0,
+		},
+		// A day averaging 50 °F has 15 heating degree days below 65 °F.
+		{
+			name: "HDD",
+			cfg:  weatherflow.DegreeDayConfig{Kind: weatherflow.HeatingDegreeDays, Base: fToC(65)},
+			min:  fToC(40), max: fToC(60),
+			want: 15 / 1.8,
+		},
+		{
+			name: "CDD",
+			cfg:  weatherflow.DegreeDayConfig{Kind: weatherflow.CoolingDegreeDays, Base: fToC(65), Cap: 20},
+			min:  fToC(70), max: fToC(90),
+			want: 15 / 1.8, // the cap only applies to growing degree days
+		},
+		// Single sine, with the base at the mean: the area above the base
+		// is amplitude/π (Baskerville & Emin, 1969).
+		{
+			name: "single sine, base at mean",
+			cfg:  weatherflow.DegreeDayConfig{Method: weatherflow.MethodSingleSine, Base: 10},
+			min:  0, max: 20,
+			want: 10 / math.Pi,
+		},
+		{
+			name: "single sine, entirely above base",
+			cfg:  weatherflow.DegreeDayConfig{Method: weatherflow.MethodSingleSine, Base: 10},
+			min:  12, max: 20,
+			want: 6,
+		},
+		{
+			name: "single sine, entirely above cap",
+			cfg:  weatherflow.DegreeDayConfig{Method: weatherflow.MethodSingleSine, Base: 10, Cap: 20},
+			min:  22, max: 30,
+			want: 10,
+		},
+	}
+
+	for _, test := range tests {
+		if got := test.cfg.Day(test.min, test.max, test.nextMin); math.Abs(got-test.want) > 1e-6 {
+			t.Errorf("%s: got %.4f, want %.4f", test.name, got, test.want)
+		}
+	}
+}
+
+// TestDegreeDaySine checks the sine methods against numerical integration of
+// the temperature curves they assume.
+func TestDegreeDaySine(t *testing.T) {
+	// integrate returns the degree days of a half day whose temperature
+	// follows a sine curve from min to max.
+	integrate := func(min, max float64, kind weatherflow.DegreeDayKind, base, cap float64) float64 {
+		const steps = 100000
+		sum := 0.0
+		for i := 0; i < steps; i++ {
+			x := (float64(i) + 0.5) / steps * math.Pi
+			temp := (min+max)/2 - (max-min)/2*math.Cos(x)
+			switch kind {
+			case weatherflow.HeatingDegreeDays:
+				sum += math.Max(0, base-temp)
+			default:
+				if cap > 0 {
+					temp = math.Min(temp, cap)
+				}
+				sum += math.Max(0, temp-base)
+			}
+		}
+		return sum / steps / 2
+	}
+
+	tests := []struct {
+		kind              weatherflow.DegreeDayKind
+		base, cap         float64
+		min, max, nextMin float64
+	}{
+		{weatherflow.GrowingDegreeDays, 10, 30, 5, 25, 8},
+		{weatherflow.GrowingDegreeDays, 10, 30, 15, 35, 12},
+		{weatherflow.GrowingDegreeDays, 10, 30, 5, 35, 2},
+		{weatherflow.GrowingDegreeDays, 10, 0, -3, 14, 6},
+		{weatherflow.HeatingDegreeDays, 18.3, 0, 5, 21, 9},
+		{weatherflow.CoolingDegreeDays, 18.3, 0, 15, 29, 17},
+	}
+
+	for _, test := range tests {
+		single := weatherflow.DegreeDayConfig{Kind: test.kind, Method: weatherflow.MethodSingleSine, Base: test.base, Cap: test.cap}
+		double := single
+		double.Method = weatherflow.MethodDoubleSine
+
+		wantSingle := 2 * integrate(test.min, test.max, test.kind, test.base, test.cap)
+		wantDouble := integrate(test.min, test.max, test.kind, test.base, test.cap) + integrate(test.nextMin, test.max, test.kind, test.base, test.cap)
+
+		if got := single.Day(test.min, test.max, test.nextMin); math.Abs(got-wantSingle) > 1e-3 {
+			t.Errorf("%v single sine %v/%v: got %.4f, want %.4f", test.kind, test.min, test.max, got, wantSingle)
+		}
+		if got := double.Day(test.min, test.max, test.nextMin); math.Abs(got-wantDouble) > 1e-3 {
+			t.Errorf("%v double sine %v/%v/%v: got %.4f, want %.4f", test.kind, test.min, test.max, test.nextMin, got, wantDouble)
+		}
+	}
+}
+
+func TestDegreeDaysAccumulator(t *testing.T) {
+	dd := weatherflow.NewDegreeDays(weatherflow.DegreeDayConfig{
+		Base:   10,
+		Season: weatherflow.SeasonStart{Month: time.April, Day: 1},
+	})
+
+	// Hourly observations from 30 March to 3 April, ranging from 5 °C at
+	// 3am to 25 °C at 3pm.
+	start := time.Date(2023, 3, 30, 0, 0, 0, 0, time.UTC)
+	for h := 0; h < 5*24; h++ {
+		ts := start.Add(time.Duration(h) * time.Hour)
+		temp := 15 - 10*math.Cos(float64(h%24-3)/24*2*math.Pi)
+		dd.Handle(&weatherflow.MessageObsSt{Obs: []weatherflow.ObsStData{{TimeEpoch: int(ts.Unix()), AirTemperature: float64Ptr(temp)}}})
+	}
+	dd.Add(weatherflow.ObsStData{TimeEpoch: int(start.Unix())}) // no temperature
+
+	// The season started on 1 April, and 3 April isn't over.
+	if got, want := dd.Season(), time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Season() = %v, want %v", got, want)
+	}
+	daily := dd.Daily()
+	if len(daily) != 2 || daily[0].Date != "2023-04-01" || daily[1].Date != "2023-04-02" {
+		t.Fatalf("Daily() = %+v, want 1 and 2 April", daily)
+	}
+	if daily[0].Min != 5 || daily[0].Max != 25 || daily[0].DegreeDays != 5 {
+		t.Errorf("1 April = %+v, want 5 to 25 °C and 5 degree days", daily[0])
+	}
+	if got := dd.Total(); got != 10 {
+		t.Errorf("Total() = %v, want 10", got)
+	}
+
+	// Observations from before the season are ignored.
+	dd.Add(weatherflow.ObsStData{TimeEpoch: int(start.Unix()), AirTemperature: float64Ptr(40)})
+	if got := dd.Total(); got != 10 {
+		t.Errorf("Total() after a late observation = %v, want 10", got)
+	}
+}
+
+func TestDegreeDaysGap(t *testing.T) {
+	cfg := weatherflow.DegreeDayConfig{Method: weatherflow.MethodDoubleSine, Base: 10}
+	dd := weatherflow.NewDegreeDays(cfg)
+
+	// 1 April, then nothing until 3 April, which is warmer.
+	add := func(day, hour int, temp float64) {
+		ts := time.Date(2023, 4, day, hour, 0, 0, 0, time.UTC)
+		dd.Add(weatherflow.ObsStData{TimeEpoch: int(ts.Unix()), AirTemperature: float64Ptr(temp)})
+	}
+	add(1, 3, 5)
+	add(1, 15, 25)
+	add(3, 3, 15)
+	add(3, 15, 25)
+	add(4, 3, 15)
+
+	daily := dd.Daily()
+	if len(daily) != 2 {
+		t.Fatalf("Daily() = %+v, want 1 and 3 April", daily)
+	}
+	// 1 April's second half uses its own minimum, not 3 April's.
+	if got, want := daily[0].DegreeDays, cfg.Day(5, 25, 5); got != want {
+		t.Errorf("1 April = %v degree days, want %v", got, want)
+	}
+	if got, want := daily[1].DegreeDays, cfg.Day(15, 25, 15); got != want {
+		t.Errorf("3 April = %v degree days, want %v", got, want)
+	}
+}
+
+func TestChillHours(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+
+	chill := weatherflow.NewChillHours(weatherflow.ChillConfig{
+		Season:   weatherflow.SeasonStart{Month: time.November, Day: 1},
+		Location: la,
+	})
+
+	// Five-minute observations through the last night of October and the
+	// first of November: in each, four hours at 3 °C, two at -2 °C and two
+	// at 9 °C.
+	temps := []float64{3, 3, 3, 3, -2, -2, 9, 9}
+	for _, night := range []time.Time{time.Date(2023, 10, 31, 0, 0, 0, 0, la), time.Date(2023, 11, 1, 0, 0, 0, 0, la)} {
+		for m := 5; m <= 8*60; m += 5 {
+			ts := night.Add(time.Duration(m) * time.Minute)
+			chill.Add(weatherflow.ObsStData{TimeEpoch: int(ts.Unix()), ReportInterval: 5, AirTemperature: float64Ptr(temps[(m-1)/60])})
+		}
+	}
+
+	if got := chill.Total(); math.Abs(got-4) > 1e-9 {
+		t.Errorf("Total() = %v, want 4", got)
+	}
+	if got, want := chill.Season(), time.Date(2023, 11, 1, 0, 0, 0, 0, la); !got.Equal(want) {
+		t.Errorf("Season() = %v, want %v", got, want)
+	}
+
+	// The older model counts everything below 7.2 °C.
+	below := weatherflow.NewChillHours(weatherflow.ChillConfig{Min: -273.15, Max: 7.2})
+	for m := 5; m <= 8*60; m += 5 {
+		below.Add(weatherflow.ObsStData{TimeEpoch: 1698796800 + m*60, ReportInterval: 5, AirTemperature: float64Ptr(temps[(m-1)/60])})
+	}
+	if got := below.Total(); math.Abs(got-6) > 1e-9 {
+		t.Errorf("below 7.2 °C: Total() = %v, want 6", got)
+	}
+}
@@ -0,0 +1,180 @@
+package weatherflow
+
+import "math"
+
+// Constants for the Magnus formula for saturation vapour pressure over water
+// (Alduchov & Eskridge, 1996), which WeatherFlow also uses.
+const (
+	magnusA = 17.625
+	magnusB = 243.04 // °C
+	magnusC = 6.1094 // hPa
+)
+
+const (
+	gasConstantDryAir = 287.05  // J/(kg·K)
+	gasConstantVapour = 461.495 // J/(kg·K)
+	zeroCelsius       = 273.15  // K
+)
+
+// Thresholds at which FeelsLike switches to wind chill or heat index,
+// matching WeatherFlow's.
+const (
+	windChillMaxTemp  = 10.0    // °C
+	windChillMinWind  = 4.8     // km/h
+	heatIndexMinTempF = 80.0    // °F
+	heatIndexMinTemp  = 26.6667 // °C
+)
+
+// The derived values below are computed from AirTemperature,
+// RelativeHumidity, StationPressure and WindAvg. Each returns false if a
+// reading it needs is missing. Temperatures are in °C, pressures in hPa.
+
+// SaturationVaporPressure returns the saturation vapour pressure at the air
+// temperature, in hPa, using the Magnus formula.
+func (obs ObsStData) SaturationVaporPressure() (float64, bool) {
+	if obs.AirTemperature == nil {
+		return 0, false
+	}
+	return saturationVaporPressure(*obs.AirTemperature), true
+}
+
+// VaporPressure returns the partial pressure of water vapour in the air, in
+// hPa.
+func (obs ObsStData) VaporPressure() (float64, bool) {
+	if obs.AirTemperature == nil || obs.RelativeHumidity == nil {
+		return 0, false
+	}
+	return *obs.RelativeHumidity / 100 * saturationVaporPressure(*obs.AirTemperature), true
+}
+
+// DewPoint returns the dew point, using the Magnus formula.
+func (obs ObsStData) DewPoint() (float64, bool) {
+	if obs.AirTemperature == nil || obs.RelativeHumidity == nil || *obs.RelativeHumidity <= 0 {
+		return 0, false
+	}
+
+	t, rh := *obs.AirTemperature, *obs.RelativeHumidity
+	gamma := math.Log(rh/100) + magnusA*t/(magnusB+t)
+	return magnusB * gamma / (magnusA - gamma), true
+}
+
+// HeatIndex returns the heat index, using the US National Weather Service's
+// algorithm: Steadman's simple formula below about 80 °F, otherwise the
+// Rothfusz regression with its low and high humidity adjustments.
+func (obs ObsStData) HeatIndex() (float64, bool) {
+	if obs.AirTemperature == nil || obs.RelativeHumidity == nil {
+		return 0, false
+	}
+
+	t, rh := celsiusToFahrenheit(*obs.AirTemperature), *obs.RelativeHumidity
+
+	hi := 0.5 * (t + 61 + (t-68)*1.2 + rh*0.094)
+	if (hi+t)/2 < heatIndexMinTempF {
+		return fahrenheitToCelsius(hi), true
+	}
+
+	hi = -42.379 + 2.04901523*t + 10.14333127*rh -
+		0.22475541*t*rh - 0.00683783*t*t - 0.05481717*rh*rh +
+		0.00122874*t*t*rh + 0.00085282*t*rh*rh - 0.00000199*t*t*rh*rh
+
+	switch {
+	case rh < 13 && t >= 80 && t <= 112:
+		hi -= (13 - rh) / 4 * math.Sqrt((17-math.Abs(t-95))/17)
+	case rh > 85 && t >= 80 && t <= 87:
+		hi += (rh - 85) / 10 * (87 - t) / 5
+	}
+	return fahrenheitToCelsius(hi), true
+}
+
+// WindChill returns the wind chill, using the North American formula
+// (Osczevski & Bluestein, 2001) with WindAvg. It is only defined at or below
+// 10 °C with wind above 4.8 km/h; otherwise the air temperature is returned.
+func (obs ObsStData) WindChill() (float64, bool) {
+	if obs.AirTemperature == nil {
+		return 0, false
+	}
+
+	t, v := *obs.AirTemperature, obs.WindAvg*3.6 // km/h
+	if t > windChillMaxTemp || v <= windChillMinWind {
+		return t, true
+	}
+
+	v16 := math.Pow(v, 0.16)
+	return 13.12 + 0.6215*t - 11.37*v16 + 0.3965*t*v16, true
+}
+
+// FeelsLike returns the apparent temperature, as WeatherFlow defines it: the
+// wind chill when it is cold and windy, the heat index when it is hot, and
+// otherwise the air temperature.
+func (obs ObsStData) FeelsLike() (float64, bool) {
+	if obs.AirTemperature == nil {
+		return 0, false
+	}
+
+	t := *obs.AirTemperature
+	switch {
+	case t <= windChillMaxTemp && obs.WindAvg*3.6 > windChillMinWind:
+		return obs.WindChill()
+	case t >= heatIndexMinTemp:
+		if hi, ok := obs.HeatIndex(); ok {
+			return hi, true
+		}
+	}
+	return t, true
+}
+
+// WetBulbTemperature returns the wet-bulb temperature, using Stull's (2011)
+// empirical formula. It is accurate to within 1 °C for relative humidity
+// between 5% and 99% and temperatures between -20 °C and 50 °C, at pressures
+// near sea level.
+func (obs ObsStData) WetBulbTemperature() (float64, bool) {
+	if obs.AirTemperature == nil || obs.RelativeHumidity == nil {
+		return 0, false
+	}
+	return wetBulb(*obs.AirTemperature, *obs.RelativeHumidity), true
+}
+
+// DeltaT returns the difference between the air temperature and the
+// wet-bulb temperature, which is used to judge spraying conditions.
+func (obs ObsStData) DeltaT() (float64, bool) {
+	tw, ok := obs.WetBulbTemperature()
+	if !ok {
+		return 0, false
+	}
+	return *obs.AirTemperature - tw, true
+}
+
+// AirDensity returns the density of the (moist) air, in kg/m³, treating it
+// as a mixture of ideal gases.
+func (obs ObsStData) AirDensity() (float64, bool) {
+	if obs.AirTemperature == nil || obs.StationPressure == nil {
+		return 0, false
+	}
+
+	var pv float64 // Pa
+	if e, ok := obs.VaporPressure(); ok {
+		pv = e * 100
+	}
+	pd := *obs.StationPressure*100 - pv
+	k := *obs.AirTemperature + zeroCelsius
+	return pd/(gasConstantDryAir*k) + pv/(gasConstantVapour*k), true
+}
+
+func saturationVaporPressure(t float64) float64 {
+	return magnusC * math.Exp(magnusA*t/(magnusB+t))
+}
+
+func wetBulb(t, rh float64) float64 {
+	return t*math.Atan(0.151977*math.Sqrt(rh+8.313659)) +
+		math.Atan(t+rh) - math.Atan(rh-1.676331) +
+		0.00391838*math.Pow(rh, 1.5)*math.Atan(0.023101*rh) -
+		4.686035
+}
+
+func celsiusToFahrenheit(t float64) float64 {
+	return t*9/5 + 32
+}
+
+func fahrenheitToCelsius(t float64) float64 {
+	return (t - 32) * 5 / 9
+}
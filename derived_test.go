@@ -0,0 +1,78 @@
+package weatherflow_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tris/weatherflow"
+)
+
+func TestDerivedMetrics(t *testing.T) {
+	type metric func(weatherflow.ObsStData) (float64, bool)
+
+	tests := []struct {
+		name   string
+		metric metric
+		obs    weatherflow.ObsStData
+		want   float64
+		wantOK bool
+	}{
+		// Dew point: Magnus formula, checked against NOAA's calculator.
+		{name: "dew point 25°C 60%", metric: weatherflow.ObsStData.DewPoint, obs: obsWith(25, 60, 0, 0), want: 16.7, wantOK: true},
+		{name: "dew point saturated", metric: weatherflow.ObsStData.DewPoint, obs: obsWith(0, 100, 0, 0), want: 0, wantOK: true},
+		{name: "dew point missing humidity", metric: weatherflow.ObsStData.DewPoint, obs: weatherflow.ObsStData{AirTemperature: float64Ptr(25)}},
+
+		// Heat index: NWS table gives 106 °F for 90 °F at 70%.
+		{name: "heat index 90°F 70%", metric: weatherflow.ObsStData.HeatIndex, obs: obsWith(32.22, 70, 0, 0), want: 41.1, wantOK: true},
+		{name: "heat index mild", metric: weatherflow.ObsStData.HeatIndex, obs: obsWith(20, 50, 0, 0), want: 19.4, wantOK: true},
+		{name: "heat index missing temperature", metric: weatherflow.ObsStData.HeatIndex, obs: weatherflow.ObsStData{RelativeHumidity: float64Ptr(50)}},
+
+		// Wind chill: Environment Canada's table gives -18 °C for -10 °C at 20 km/h.
+		{name: "wind chill -10°C 20km/h", metric: weatherflow.ObsStData.WindChill, obs: obsWith(-10, 50, 0, 20/3.6), want: -17.9, wantOK: true},
+		{name: "wind chill calm", metric: weatherflow.ObsStData.WindChill, obs: obsWith(-10, 50, 0, 1), want: -10, wantOK: true},
+		{name: "wind chill warm", metric: weatherflow.ObsStData.WindChill, obs: obsWith(15, 50, 0, 10), want: 15, wantOK: true},
+
+		{name: "feels like cold", metric: weatherflow.ObsStData.FeelsLike, obs: obsWith(-10, 50, 0, 20/3.6), want: -17.9, wantOK: true},
+		{name: "feels like hot", metric: weatherflow.ObsStData.FeelsLike, obs: obsWith(32.22, 70, 0, 5), want: 41.1, wantOK: true},
+		{name: "feels like mild", metric: weatherflow.ObsStData.FeelsLike, obs: obsWith(18, 70, 0, 5), want: 18, wantOK: true},
+		{name: "feels like missing temperature", metric: weatherflow.ObsStData.FeelsLike, obs: weatherflow.ObsStData{}},
+
+		// Wet bulb: Stull (2011) gives 13.7 °C for 20 °C at 50%.
+		{name: "wet bulb 20°C 50%", metric: weatherflow.ObsStData.WetBulbTemperature, obs: obsWith(20, 50, 0, 0), want: 13.7, wantOK: true},
+		{name: "delta T 20°C 50%", metric: weatherflow.ObsStData.DeltaT, obs: obsWith(20, 50, 0, 0), want: 6.3, wantOK: true},
+		{name: "delta T missing humidity", metric: weatherflow.ObsStData.DeltaT, obs: weatherflow.ObsStData{AirTemperature: float64Ptr(20)}},
+
+		// Air density: 1.225 kg/m³ for the ISA sea level atmosphere.
+		{name: "air density ISA", metric: weatherflow.ObsStData.AirDensity, obs: obsWith(15, 0, 1013.25, 0), want: 1.225, wantOK: true},
+		{name: "air density humid", metric: weatherflow.ObsStData.AirDensity, obs: obsWith(30, 80, 1000, 0), want: 1.134, wantOK: true},
+		{name: "air density missing pressure", metric: weatherflow.ObsStData.AirDensity, obs: obsWith(15, 50, 0, 0)},
+
+		{name: "saturation vapor pressure 20°C", metric: weatherflow.ObsStData.SaturationVaporPressure, obs: obsWith(20, 50, 0, 0), want: 23.33, wantOK: true},
+		{name: "vapor pressure 25°C 60%", metric: weatherflow.ObsStData.VaporPressure, obs: obsWith(25, 60, 0, 0), want: 18.97, wantOK: true},
+	}
+
+	for _, test := range tests {
+		got, ok := test.metric(test.obs)
+		if ok != test.wantOK {
+			t.Errorf("%s: ok = %v, want %v", test.name, ok, test.wantOK)
+			continue
+		}
+		if ok && math.Abs(got-test.want) > 0.05 {
+			t.Errorf("%s: got %.3f, want %.3f", test.name, got, test.want)
+		}
+	}
+}
+
+// obsWith returns an observation with the given readings. A zero pressure is
+// treated as missing.
+func obsWith(temp, humidity, pressure, wind float64) weatherflow.ObsStData {
+	o := weatherflow.ObsStData{
+		AirTemperature:   float64Ptr(temp),
+		RelativeHumidity: float64Ptr(humidity),
+		WindAvg:          wind,
+	}
+	if pressure != 0 {
+		o.StationPressure = float64Ptr(pressure)
+	}
+	return o
+}
@@ -0,0 +1,65 @@
+package weatherflow
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+
+	"nhooyr.io/websocket"
+)
+
+// DialOptions configures how the Client connects to the WeatherFlow server.
+type DialOptions struct {
+	// HTTPClient is used for the WebSocket handshake. If set, Proxy and
+	// TLSConfig are ignored; configure its Transport instead.
+	HTTPClient *http.Client
+
+	// HTTPHeader is added to the handshake request.
+	HTTPHeader http.Header
+
+	// Proxy selects the proxy for the handshake request. Defaults to
+	// http.ProxyFromEnvironment.
+	Proxy func(*http.Request) (*url.URL, error)
+
+	// TLSConfig configures the TLS connection, e.g. to trust a private CA.
+	TLSConfig *tls.Config
+
+	// CompressionMode and CompressionThreshold control permessage-deflate
+	// compression. See the websocket package for details.
+	CompressionMode      websocket.CompressionMode
+	CompressionThreshold int
+}
+
+// SetDialOptions sets the options used for each new connection.
+func (c *Client) SetDialOptions(opts *DialOptions) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if opts == nil {
+		c.dialOpts = nil
+		return
+	}
+	c.dialOpts = opts.wsOptions()
+}
+
+// wsOptions converts opts to the form expected by websocket.Dial.
+func (opts *DialOptions) wsOptions() *websocket.DialOptions {
+	httpClient := opts.HTTPClient
+	if httpClient == nil && (opts.Proxy != nil || opts.TLSConfig != nil) {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if opts.Proxy != nil {
+			transport.Proxy = opts.Proxy
+		}
+		if opts.TLSConfig != nil {
+			transport.TLSClientConfig = opts.TLSConfig.Clone()
+		}
+		httpClient = &http.Client{Transport: transport}
+	}
+
+	return &websocket.DialOptions{
+		HTTPClient:           httpClient,
+		HTTPHeader:           opts.HTTPHeader.Clone(),
+		CompressionMode:      opts.CompressionMode,
+		CompressionThreshold: opts.CompressionThreshold,
+	}
+}
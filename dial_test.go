@@ -0,0 +1,133 @@
+package weatherflow_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tris/weatherflow"
+)
+
+func TestDialOptionsTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Test"); got != "hello" {
+			http.Error(w, "missing header", http.StatusBadRequest)
+			return
+		}
+		mockServerHandler(w, r)
+	}))
+	defer server.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+
+	tests := []struct {
+		name    string
+		opts    *weatherflow.DialOptions
+		wantErr bool
+	}{
+		{
+			name:    "untrusted certificate",
+			opts:    &weatherflow.DialOptions{HTTPHeader: http.Header{"X-Test": {"hello"}}},
+			wantErr: true,
+		},
+		{
+			name: "trusted CA and header",
+			opts: &weatherflow.DialOptions{
+				HTTPHeader: http.Header{"X-Test": {"hello"}},
+				TLSConfig:  &tls.Config{RootCAs: pool},
+			},
+		},
+		{
+			name: "custom HTTP client",
+			opts: &weatherflow.DialOptions{
+				HTTPHeader: http.Header{"X-Test": {"hello"}},
+				HTTPClient: server.Client(),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			client := weatherflow.NewClient("your_token", nil, t.Logf)
+			client.SetURL(server.URL + "/ws")
+			client.SetDialOptions(test.opts)
+			client.AddDevice(12345)
+
+			states := make(chan weatherflow.State, 10)
+			client.OnStateChange(func(s weatherflow.State) {
+				states <- s
+			})
+			msgCh := make(chan weatherflow.Message, 10)
+			client.Start(func(msg weatherflow.Message) {
+				msgCh <- msg
+			})
+			defer client.Stop()
+
+			if test.wantErr {
+				for {
+					select {
+					case s := <-states:
+						if s.Conn != weatherflow.StateBackingOff {
+							continue
+						}
+						var netErr *weatherflow.NetworkError
+						if !errors.As(s.LastError, &netErr) {
+							t.Errorf("LastError = %v, want *NetworkError", s.LastError)
+						}
+						return
+					case <-msgCh:
+						t.Fatal("Received a message over an untrusted connection")
+					case <-time.After(5 * time.Second):
+						t.Fatal("Timed out waiting for connection failure")
+					}
+				}
+			}
+
+			select {
+			case <-msgCh:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Timed out waiting for message")
+			}
+		})
+	}
+}
+
+func TestDialOptionsProxy(t *testing.T) {
+	wsURL, stopServer := startMockServer()
+	defer stopServer()
+
+	var proxied int32
+	client := weatherflow.NewClient("your_token", nil, t.Logf)
+	client.SetURL(wsURL)
+	client.SetDialOptions(&weatherflow.DialOptions{
+		Proxy: func(r *http.Request) (*url.URL, error) {
+			atomic.AddInt32(&proxied, 1)
+			return nil, nil // connect directly
+		},
+	})
+	client.AddDevice(12345)
+
+	msgCh := make(chan weatherflow.Message, 10)
+	client.Start(func(msg weatherflow.Message) {
+		msgCh <- msg
+	})
+	defer client.Stop()
+
+	select {
+	case <-msgCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for message")
+	}
+
+	if atomic.LoadInt32(&proxied) == 0 {
+		t.Error("Proxy function was not consulted")
+	}
+}
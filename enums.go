@@ -0,0 +1,130 @@
+package weatherflow
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// PrecipitationType is the kind of precipitation detected by a Tempest.
+type PrecipitationType int
+
+const (
+	PrecipitationNone     PrecipitationType = 0
+	PrecipitationRain     PrecipitationType = 1
+	PrecipitationHail     PrecipitationType = 2
+	PrecipitationRainHail PrecipitationType = 3 // rain and hail together
+)
+
+var precipitationTypeNames = map[PrecipitationType]string{
+	PrecipitationNone:     "none",
+	PrecipitationRain:     "rain",
+	PrecipitationHail:     "hail",
+	PrecipitationRainHail: "rain+hail",
+}
+
+func (p PrecipitationType) String() string {
+	if name, ok := precipitationTypeNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("PrecipitationType(%d)", int(p))
+}
+
+// Valid reports whether p is a documented value.
+func (p PrecipitationType) Valid() bool {
+	_, ok := precipitationTypeNames[p]
+	return ok
+}
+
+// UnmarshalJSON accepts either the numeric code used on the wire or a name
+// as returned by String. Undocumented codes are kept; use Valid to check.
+func (p *PrecipitationType) UnmarshalJSON(data []byte) error {
+	v, err := unmarshalEnum(data, "precipitation type", precipitationTypeNames)
+	if err == nil {
+		*p = v
+	}
+	return err
+}
+
+// PrecipitationAnalysisType describes how rain accumulation was checked by
+// WeatherFlow's RainCheck.
+type PrecipitationAnalysisType int
+
+const (
+	AnalysisNone                PrecipitationAnalysisType = 0
+	AnalysisRainCheckDisplayOn  PrecipitationAnalysisType = 1 // RainCheck with user display on
+	AnalysisRainCheckDisplayOff PrecipitationAnalysisType = 2 // RainCheck with user display off
+)
+
+var analysisTypeNames = map[PrecipitationAnalysisType]string{
+	AnalysisNone:                "none",
+	AnalysisRainCheckDisplayOn:  "raincheck display on",
+	AnalysisRainCheckDisplayOff: "raincheck display off",
+}
+
+func (a PrecipitationAnalysisType) String() string {
+	if name, ok := analysisTypeNames[a]; ok {
+		return name
+	}
+	return fmt.Sprintf("PrecipitationAnalysisType(%d)", int(a))
+}
+
+// Valid reports whether a is a documented value.
+func (a PrecipitationAnalysisType) Valid() bool {
+	_, ok := analysisTypeNames[a]
+	return ok
+}
+
+// UnmarshalJSON accepts either the numeric code used on the wire or a name
+// as returned by String. Undocumented codes are kept; use Valid to check.
+func (a *PrecipitationAnalysisType) UnmarshalJSON(data []byte) error {
+	v, err := unmarshalEnum(data, "precipitation analysis type", analysisTypeNames)
+	if err == nil {
+		*a = v
+	}
+	return err
+}
+
+// PressureTrend is the server's assessment of recent pressure change.
+type PressureTrend string
+
+const (
+	PressureFalling PressureTrend = "falling"
+	PressureSteady  PressureTrend = "steady"
+	PressureRising  PressureTrend = "rising"
+)
+
+func (t PressureTrend) String() string {
+	return string(t)
+}
+
+// Valid reports whether t is a documented value.
+func (t PressureTrend) Valid() bool {
+	switch t {
+	case PressureFalling, PressureSteady, PressureRising:
+		return true
+	}
+	return false
+}
+
+// unmarshalEnum decodes an integer enum from its numeric code or its name.
+func unmarshalEnum[T ~int](data []byte, what string, names map[T]string) (T, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		var name string
+		if err := json.Unmarshal(data, &name); err != nil {
+			return 0, err
+		}
+		for v, n := range names {
+			if n == name {
+				return v, nil
+			}
+		}
+		return 0, fmt.Errorf("unknown %s %q", what, name)
+	}
+
+	var code int
+	if err := json.Unmarshal(data, &code); err != nil {
+		return 0, err
+	}
+	return T(code), nil
+}
@@ -0,0 +1,105 @@
+package weatherflow_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/tris/weatherflow"
+)
+
+func TestPrecipitationType(t *testing.T) {
+	tests := []struct {
+		input     string
+		want      weatherflow.PrecipitationType
+		wantName  string
+		wantValid bool
+		wantError bool
+	}{
+		{input: `0`, want: weatherflow.PrecipitationNone, wantName: "none", wantValid: true},
+		{input: `1`, want: weatherflow.PrecipitationRain, wantName: "rain", wantValid: true},
+		{input: `2`, want: weatherflow.PrecipitationHail, wantName: "hail", wantValid: true},
+		{input: `3`, want: weatherflow.PrecipitationRainHail, wantName: "rain+hail", wantValid: true},
+		{input: `"hail"`, want: weatherflow.PrecipitationHail, wantName: "hail", wantValid: true},
+		{input: `7`, want: 7, wantName: "PrecipitationType(7)", wantValid: false},
+		{input: `"snow"`, wantError: true},
+	}
+
+	for _, test := range tests {
+		var got weatherflow.PrecipitationType
+		err := json.Unmarshal([]byte(test.input), &got)
+		if test.wantError {
+			if err == nil {
+				t.Errorf("Unmarshal(%s): expected an error but got none", test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unmarshal(%s): unexpected error: %v", test.input, err)
+			continue
+		}
+		if got != test.want || got.String() != test.wantName || got.Valid() != test.wantValid {
+			t.Errorf("Unmarshal(%s) = %v (%d, valid %v), want %v (%d, valid %v)",
+				test.input, got, int(got), got.Valid(), test.wantName, int(test.want), test.wantValid)
+		}
+	}
+}
+
+func TestPrecipitationAnalysisType(t *testing.T) {
+	tests := []struct {
+		input     string
+		want      weatherflow.PrecipitationAnalysisType
+		wantName  string
+		wantValid bool
+	}{
+		{input: `0`, want: weatherflow.AnalysisNone, wantName: "none", wantValid: true},
+		{input: `1`, want: weatherflow.AnalysisRainCheckDisplayOn, wantName: "raincheck display on", wantValid: true},
+		{input: `2`, want: weatherflow.AnalysisRainCheckDisplayOff, wantName: "raincheck display off", wantValid: true},
+		{input: `"raincheck display off"`, want: weatherflow.AnalysisRainCheckDisplayOff, wantName: "raincheck display off", wantValid: true},
+		{input: `9`, want: 9, wantName: "PrecipitationAnalysisType(9)", wantValid: false},
+	}
+
+	for _, test := range tests {
+		var got weatherflow.PrecipitationAnalysisType
+		if err := json.Unmarshal([]byte(test.input), &got); err != nil {
+			t.Errorf("Unmarshal(%s): unexpected error: %v", test.input, err)
+			continue
+		}
+		if got != test.want || got.String() != test.wantName || got.Valid() != test.wantValid {
+			t.Errorf("Unmarshal(%s) = %v (%d, valid %v), want %v (%d, valid %v)",
+				test.input, got, int(got), got.Valid(), test.wantName, int(test.want), test.wantValid)
+		}
+	}
+}
+
+func TestPressureTrend(t *testing.T) {
+	for _, trend := range []weatherflow.PressureTrend{weatherflow.PressureFalling, weatherflow.PressureSteady, weatherflow.PressureRising} {
+		if !trend.Valid() {
+			t.Errorf("%v.Valid() = false, want true", trend)
+		}
+	}
+	if weatherflow.PressureTrend("unknown").Valid() {
+		t.Error(`PressureTrend("unknown").Valid() = true, want false`)
+	}
+}
+
+func TestEnumsInMessages(t *testing.T) {
+	input := `{"status":{"status_code":0,"status_message":"SUCCESS"},"device_id":121037,"type":"obs_st","summary":{"pressure_trend":"rising","precip_analysis_type_yesterday":1},"obs":[[1681767864,4.19,4.24,4.27,285,20,722.7,null,null,109435,6.19,912,0.1,3,0,0,2.46,1,0,0,0,2]]}`
+	msg, err := weatherflow.UnmarshalMessage([]byte(input))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	obsSt := msg.(*weatherflow.MessageObsSt)
+	if got := obsSt.Summary.PressureTrend; got != weatherflow.PressureRising {
+		t.Errorf("PressureTrend = %v, want %v", got, weatherflow.PressureRising)
+	}
+	if got := obsSt.Summary.PrecipAnalysisTypeYesterday; got != weatherflow.AnalysisRainCheckDisplayOn {
+		t.Errorf("PrecipAnalysisTypeYesterday = %v, want %v", got, weatherflow.AnalysisRainCheckDisplayOn)
+	}
+	if got := obsSt.Obs[0].PrecipitationType; got != weatherflow.PrecipitationRainHail {
+		t.Errorf("PrecipitationType = %v, want %v", got, weatherflow.PrecipitationRainHail)
+	}
+	if got := obsSt.Obs[0].PrecipitationAnalysisType; got != weatherflow.AnalysisRainCheckDisplayOff {
+		t.Errorf("PrecipitationAnalysisType = %v, want %v", got, weatherflow.AnalysisRainCheckDisplayOff)
+	}
+}
@@ -0,0 +1,106 @@
+package weatherflow
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// AuthError is returned when the server rejects the API token during the
+// WebSocket handshake. It is permanent: the Client stops instead of
+// retrying.
+type AuthError struct {
+	StatusCode int // HTTP status of the handshake response
+	Err        error
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("authentication failed (HTTP %d): %v", e.StatusCode, e.Err)
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// NetworkError is returned when dialing, reading from or writing to the
+// connection fails. The Client retries after these.
+type NetworkError struct {
+	Op  string // "dial", "read", "write" or "ping"
+	Err error
+}
+
+func (e *NetworkError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Op, e.Err)
+}
+
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// ProtocolError is returned when the server sends something the Client
+// doesn't understand, such as a binary frame or an unsupported message type.
+type ProtocolError struct {
+	Err error
+}
+
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("protocol error: %v", e.Err)
+}
+
+func (e *ProtocolError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeError is returned when a message isn't valid JSON or doesn't match
+// the structure expected for its type.
+type DecodeError struct {
+	Type string // message type, if it could be determined
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Type == "" {
+		return fmt.Sprintf("decoding message: %v", e.Err)
+	}
+	return fmt.Sprintf("decoding %s message: %v", e.Type, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DeviceError reports that the server returned an error status for a
+// device, instead of (or along with) its observations.
+type DeviceError struct {
+	DeviceID      int
+	StatusCode    int
+	StatusMessage string
+}
+
+func (e *DeviceError) Error() string {
+	return fmt.Sprintf("device %d: %s (status %d)", e.DeviceID, e.StatusMessage, e.StatusCode)
+}
+
+// NotPermitted reports whether the error means the token isn't allowed to
+// access the device, so retrying the subscription won't help.
+func (e *DeviceError) NotPermitted() bool {
+	if e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden {
+		return true
+	}
+
+	msg := strings.ToLower(e.StatusMessage)
+	for _, s := range []string{"unauthorized", "not authorized", "forbidden", "not permitted", "permission"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// dialError classifies a failed handshake.
+func dialError(resp *http.Response, err error) error {
+	if resp != nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+		return &AuthError{StatusCode: resp.StatusCode, Err: err}
+	}
+	return &NetworkError{Op: "dial", Err: err}
+}
@@ -0,0 +1,432 @@
+package weatherflow
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Constants from FAO Irrigation and Drainage Paper 56 (Allen et al., 1998).
+const (
+	solarConstant       = 0.0820    // MJ/(m²·min)
+	stefanBoltzmannDay  = 4.903e-9  // MJ/(K⁴·m²·day)
+	stefanBoltzmannHour = 2.043e-10 // MJ/(K⁴·m²·hour)
+	albedo              = 0.23      // of the grass reference crop
+	defaultNightRsRso   = 0.8
+	defaultDepletionP   = 0.5
+)
+
+// ET0DailyInput holds a day's weather for DailyET0.
+type ET0DailyInput struct {
+	Date           time.Time // only the date is used
+	TMin, TMax     float64   // °C
+	RHMin, RHMax   float64   // %
+	Wind           float64   // mean wind speed at 2 m, m/s
+	SolarRadiation float64   // MJ/m² over the day
+	Pressure       float64   // mean station pressure, hPa; zero to estimate it from the elevation
+}
+
+// ET0HourlyInput holds an hour's weather for HourlyET0.
+type ET0HourlyInput struct {
+	Time           time.Time // start of the hour
+	Temp           float64   // mean, °C
+	RH             float64   // mean, %
+	Wind           float64   // mean wind speed at 2 m, m/s
+	SolarRadiation float64   // MJ/m² over the hour
+	Pressure       float64   // mean station pressure, hPa; zero to estimate it from the elevation
+
+	// NightRsRso is the relative shortwave radiation (cloudiness) assumed
+	// while the sun is down, ideally the ratio from two to three hours
+	// before sunset. The default is 0.8.
+	NightRsRso float64
+}
+
+// DailyET0 returns the FAO-56 Penman-Monteith reference evapotranspiration
+// for a day, in mm, at a station's latitude and elevation (FAO-56 equation
+// 6).
+func DailyET0(site DeviceInfo, in ET0DailyInput) float64 {
+	phi := site.Latitude * math.Pi / 180
+	dr, decl := solarGeometry(in.Date.YearDay())
+	ws := sunsetHourAngle(phi, decl)
+	ra := 24 * 60 / math.Pi * solarConstant * dr *
+		(ws*math.Sin(phi)*math.Sin(decl) + math.Cos(phi)*math.Cos(decl)*math.Sin(ws))
+	rso := (0.75 + 2e-5*site.Elevation) * ra
+
+	ea := (saturationVaporPressureKPa(in.TMin)*in.RHMax/100 + saturationVaporPressureKPa(in.TMax)*in.RHMin/100) / 2
+	es := (saturationVaporPressureKPa(in.TMax) + saturationVaporPressureKPa(in.TMin)) / 2
+
+	rnl := stefanBoltzmannDay * (math.Pow(in.TMax+zeroCelsius, 4) + math.Pow(in.TMin+zeroCelsius, 4)) / 2 *
+		(0.34 - 0.14*math.Sqrt(ea)) * (1.35*relativeRadiation(in.SolarRadiation, rso, defaultNightRsRso) - 0.35)
+	rn := (1-albedo)*in.SolarRadiation - rnl
+
+	t := (in.TMax + in.TMin) / 2
+	delta := vaporPressureSlope(t)
+	gamma := psychrometricConstant(in.Pressure, site.Elevation)
+
+	return (0.408*delta*rn + gamma*900/(t+273)*in.Wind*(es-ea)) /
+		(delta + gamma*(1+0.34*in.Wind))
+}
+
+// HourlyET0 returns the FAO-56 Penman-Monteith reference evapotranspiration
+// for an hour, in mm, at a station's latitude, longitude and elevation
+// (FAO-56 equation 53).
+func HourlyET0(site DeviceInfo, in ET0HourlyInput) float64 {
+	ra, _ := hourlyExtraterrestrialRadiation(site, in.Time)
+	rso := (0.75 + 2e-5*site.Elevation) * ra
+
+	night := in.NightRsRso
+	if night == 0 {
+		night = defaultNightRsRso
+	}
+
+	ea := saturationVaporPressureKPa(in.Temp) * in.RH / 100
+	rnl := stefanBoltzmannHour * math.Pow(in.Temp+zeroCelsius, 4) *
+		(0.34 - 0.14*math.Sqrt(ea)) * (1.35*relativeRadiation(in.SolarRadiation, rso, night) - 0.35)
+	rn := (1-albedo)*in.SolarRadiation - rnl
+
+	// Soil heat flux.
+	g := 0.1 * rn
+	if ra <= 0 {
+		g = 0.5 * rn
+	}
+
+	delta := vaporPressureSlope(in.Temp)
+	gamma := psychrometricConstant(in.Pressure, site.Elevation)
+
+	return (0.408*delta*(rn-g) + gamma*37/(in.Temp+273)*in.Wind*(saturationVaporPressureKPa(in.Temp)-ea)) /
+		(delta + gamma*(1+0.34*in.Wind))
+}
+
+// hourlyExtraterrestrialRadiation returns the extraterrestrial radiation, in
+// MJ/m², over the hour starting at start (FAO-56 equation 28), and how many
+// hours before sunset the middle of the hour is.
+func hourlyExtraterrestrialRadiation(site DeviceInfo, start time.Time) (ra, beforeSunset float64) {
+	phi := site.Latitude * math.Pi / 180
+	utc := start.UTC()
+	dr, decl := solarGeometry(utc.YearDay())
+	ws := sunsetHourAngle(phi, decl)
+
+	w := solarHourAngle(utc.Add(30*time.Minute), site.Longitude) // middle of the hour
+	w1 := math.Max(-ws, math.Min(w-math.Pi/24, ws))
+	w2 := math.Max(-ws, math.Min(w+math.Pi/24, ws))
+
+	ra = 12 * 60 / math.Pi * solarConstant * dr *
+		((w2-w1)*math.Sin(phi)*math.Sin(decl) + math.Cos(phi)*math.Cos(decl)*(math.Sin(w2)-math.Sin(w1)))
+	return ra, (ws - w) * 12 / math.Pi
+}
+
+// WindAt2m converts a wind speed measured height metres above ground to the
+// 2 m height FAO-56 assumes (equation 47). A height of zero is taken to mean
+// the speed is already at 2 m.
+func WindAt2m(speed, height float64) float64 {
+	if height <= 0 {
+		return speed
+	}
+	return speed * 4.87 / math.Log(67.8*height-5.42)
+}
+
+// solarGeometry returns the inverse relative Earth-Sun distance and the
+// solar declination, in radians, for a day of the year.
+func solarGeometry(day int) (dr, decl float64) {
+	x := 2 * math.Pi * float64(day) / 365
+	return 1 + 0.033*math.Cos(x), 0.409 * math.Sin(x-1.39)
+}
+
+// solarHourAngle returns the hour angle of the sun, in radians, at a
+// longitude, from its solar time (FAO-56 equations 31 to 33).
+func solarHourAngle(t time.Time, longitude float64) float64 {
+	t = t.UTC()
+	b := 2 * math.Pi * float64(t.YearDay()-81) / 364
+	sc := 0.1645*math.Sin(2*b) - 0.1255*math.Cos(b) - 0.025*math.Sin(b)
+	hours := float64(t.Hour()) + float64(t.Minute())/60 + float64(t.Second())/3600
+	return math.Pi / 12 * (hours + longitude/15 + sc - 12)
+}
+
+// sunsetHourAngle returns the sunset hour angle, in radians, allowing for
+// polar day and night.
+func sunsetHourAngle(phi, decl float64) float64 {
+	return math.Acos(math.Max(-1, math.Min(1, -math.Tan(phi)*math.Tan(decl))))
+}
+
+// relativeRadiation returns Rs/Rso, limited to 1, or night when there is no
+// clear-sky radiation to compare with.
+func relativeRadiation(rs, rso, night float64) float64 {
+	if rso <= 0 {
+		return night
+	}
+	return math.Min(rs/rso, 1)
+}
+
+// saturationVaporPressureKPa returns the saturation vapour pressure in kPa,
+// by FAO-56 equation 11. It differs slightly from the Magnus constants used
+// elsewhere in this package, so that results match FAO-56's.
+func saturationVaporPressureKPa(t float64) float64 {
+	return 0.6108 * math.Exp(17.27*t/(t+237.3))
+}
+
+// vaporPressureSlope returns the slope of the saturation vapour pressure
+// curve, in kPa/°C (FAO-56 equation 13).
+func vaporPressureSlope(t float64) float64 {
+	return 4098 * saturationVaporPressureKPa(t) / ((t + 237.3) * (t + 237.3))
+}
+
+// psychrometricConstant returns γ in kPa/°C from the station pressure in
+// hPa, or if that is zero, from the pressure of a standard atmosphere at the
+// elevation (FAO-56 equations 7 and 8).
+func psychrometricConstant(pressure, elevation float64) float64 {
+	if pressure == 0 {
+		pressure = standardAtmospherePressure(elevation)
+	}
+	return 0.665e-4 * pressure
+}
+
+// standardAtmospherePressure returns the pressure of a standard atmosphere at an
+// elevation, in hPa.
+func standardAtmospherePressure(elevation float64) float64 {
+	return 1013 * math.Pow((293-0.0065*elevation)/293, 5.26)
+}
+
+// ET0Hour is the reference evapotranspiration over an hour.
+type ET0Hour struct {
+	Start time.Time
+	ET0   float64 // mm
+	Rain  float64 // mm
+}
+
+// ET0Day is the reference evapotranspiration over a local day.
+type ET0Day struct {
+	Date string  // e.g. "2023-06-01"
+	ET0  float64 // mm, from the daily equation
+	Rain float64 // mm
+}
+
+// ET0Tracker computes hourly and daily reference evapotranspiration from
+// one device's observations. Wind speeds are converted to 2 m using the
+// device's Height. It is safe for concurrent use.
+type ET0Tracker struct {
+	deviceID int
+	site     DeviceInfo
+	onHour   func(ET0Hour)
+	onDay    func(ET0Day)
+	hour     *et0Period
+	day      *et0Period
+	night    float64 // Rs/Rso of the latest hour 2 to 3 hours before sunset
+	mu       sync.Mutex
+}
+
+// et0Period accumulates the weather over an hour or a day.
+type et0Period struct {
+	start                 time.Time
+	key                   string
+	n                     int
+	temp, rh, wind, solar float64 // sums
+	pressure              float64
+	pressureN             int
+	rain                  float64
+	tMin, tMax            float64
+	rhMin, rhMax          float64
+}
+
+func (p *et0Period) add(obs ObsStData, wind float64) {
+	temp, rh := *obs.AirTemperature, *obs.RelativeHumidity
+	if p.n == 0 {
+		p.tMin, p.tMax, p.rhMin, p.rhMax = temp, temp, rh, rh
+	}
+	p.n++
+	p.temp += temp
+	p.rh += rh
+	p.wind += wind
+	p.solar += float64(obs.SolarRadiation)
+	p.rain += obs.RainAccumulated
+	p.tMin, p.tMax = math.Min(p.tMin, temp), math.Max(p.tMax, temp)
+	p.rhMin, p.rhMax = math.Min(p.rhMin, rh), math.Max(p.rhMax, rh)
+	if obs.StationPressure != nil {
+		p.pressure += *obs.StationPressure
+		p.pressureN++
+	}
+}
+
+func (p *et0Period) meanPressure() float64 {
+	if p.pressureN == 0 {
+		return 0
+	}
+	return p.pressure / float64(p.pressureN)
+}
+
+// NewET0Tracker creates an ET0Tracker for a device, described by site, which
+// must have its Latitude, Longitude and Elevation set (see
+// StationMetadata.DeviceInfo). onHour and onDay, either of
+// which may be nil, are called as each hour and local day completes, when
+// the first observation after it arrives.
+func NewET0Tracker(deviceID int, site DeviceInfo, onHour func(ET0Hour), onDay func(ET0Day)) *ET0Tracker {
+	if site.Location == nil {
+		site.Location = time.UTC
+	}
+	return &ET0Tracker{
+		deviceID: deviceID,
+		site:     site,
+		onHour:   onHour,
+		onDay:    onDay,
+		night:    defaultNightRsRso,
+	}
+}
+
+// Handle adds the observations in an obs_st message from the tracker's
+// device. Other messages are ignored.
+func (e *ET0Tracker) Handle(msg Message) {
+	if m, ok := msg.(*MessageObsSt); ok && m.DeviceID == e.deviceID {
+		for _, obs := range m.Obs {
+			e.Add(obs)
+		}
+	}
+}
+
+// Add records an observation. Observations without temperature or humidity
+// are skipped.
+func (e *ET0Tracker) Add(obs ObsStData) {
+	if obs.AirTemperature == nil || obs.RelativeHumidity == nil {
+		return
+	}
+
+	t := obs.Time().In(e.site.Location)
+	hourStart := t.Truncate(time.Hour)
+	date := t.Format(DayLayout)
+	wind := WindAt2m(obs.WindAvg, e.site.Height)
+
+	var hour *ET0Hour
+	var day *ET0Day
+
+	e.mu.Lock()
+	if e.hour != nil && hourStart.After(e.hour.start) {
+		h := e.finishHour()
+		hour = &h
+	}
+	if e.day != nil && date > e.day.key {
+		d := e.finishDay()
+		day = &d
+	}
+	if e.hour == nil {
+		e.hour = &et0Period{start: hourStart}
+	}
+	if e.day == nil {
+		e.day = &et0Period{key: date}
+	}
+	if !hourStart.Before(e.hour.start) {
+		e.hour.add(obs, wind)
+	}
+	if date >= e.day.key {
+		e.day.add(obs, wind)
+	}
+	e.mu.Unlock()
+
+	if hour != nil && e.onHour != nil {
+		e.onHour(*hour)
+	}
+	if day != nil && e.onDay != nil {
+		e.onDay(*day)
+	}
+}
+
+// finishHour computes the ET0 of the current hour. e.mu must be held.
+func (e *ET0Tracker) finishHour() ET0Hour {
+	p := e.hour
+	e.hour = nil
+	n := float64(p.n)
+	rs := p.solar / n * 3600 / 1e6
+
+	// FAO-56 takes the cloudiness at night from the Rs/Rso of the period 2
+	// to 3 hours before sunset.
+	if ra, before := hourlyExtraterrestrialRadiation(e.site, p.start); before > 2 && before <= 3 && ra > 0 {
+		e.night = relativeRadiation(rs, (0.75+2e-5*e.site.Elevation)*ra, e.night)
+	}
+
+	in := ET0HourlyInput{
+		Time:           p.start,
+		Temp:           p.temp / n,
+		RH:             p.rh / n,
+		Wind:           p.wind / n,
+		SolarRadiation: rs,
+		Pressure:       p.meanPressure(),
+		NightRsRso:     e.night,
+	}
+	return ET0Hour{Start: p.start, ET0: HourlyET0(e.site, in), Rain: p.rain}
+}
+
+// finishDay computes the ET0 of the current day. e.mu must be held.
+func (e *ET0Tracker) finishDay() ET0Day {
+	p := e.day
+	e.day = nil
+	n := float64(p.n)
+
+	date, _ := time.ParseInLocation(DayLayout, p.key, e.site.Location)
+	in := ET0DailyInput{
+		Date:           date,
+		TMin:           p.tMin,
+		TMax:           p.tMax,
+		RHMin:          p.rhMin,
+		RHMax:          p.rhMax,
+		Wind:           p.wind / n,
+		SolarRadiation: p.solar / n * 86400 / 1e6,
+		Pressure:       p.meanPressure(),
+	}
+	return ET0Day{Date: p.key, ET0: DailyET0(e.site, in), Rain: p.rain}
+}
+
+// SoilWaterBalance tracks the root zone depletion of a crop with the FAO-56
+// daily water balance (chapter 8), ignoring runoff and capillary rise.
+type SoilWaterBalance struct {
+	// TAW is the total available water in the root zone, in mm.
+	TAW float64
+
+	// P is the fraction of TAW the crop can extract without stress. The
+	// default is 0.5.
+	P float64
+
+	// Kc is the crop coefficient. The default is 1, the reference crop.
+	Kc float64
+
+	// Depletion is the water, in mm, needed to bring the root zone back to
+	// field capacity.
+	Depletion float64
+}
+
+// RAW returns the readily available water, in mm.
+func (b *SoilWaterBalance) RAW() float64 {
+	p := b.P
+	if p == 0 {
+		p = defaultDepletionP
+	}
+	return p * b.TAW
+}
+
+// Step advances the balance by a day with the given rain (or irrigation)
+// and reference evapotranspiration, in mm. It returns the crop's actual
+// evapotranspiration, reduced under water stress, and the water lost to deep
+// percolation.
+func (b *SoilWaterBalance) Step(rain, et0 float64) (etc, drainage float64) {
+	kc := b.Kc
+	if kc == 0 {
+		kc = 1
+	}
+
+	// Water stress coefficient (FAO-56 equation 84).
+	ks := 1.0
+	if raw := b.RAW(); b.Depletion > raw && b.TAW > raw {
+		ks = math.Max(0, (b.TAW-b.Depletion)/(b.TAW-raw))
+	}
+	etc = ks * kc * et0
+
+	b.Depletion += etc - rain
+	if b.Depletion < 0 {
+		drainage = -b.Depletion
+		b.Depletion = 0
+	}
+	b.Depletion = math.Min(b.Depletion, b.TAW)
+	return etc, drainage
+}
+
+// NeedsIrrigation reports whether the depletion has reached the readily
+// available water, beyond which the crop is stressed.
+func (b *SoilWaterBalance) NeedsIrrigation() bool {
+	return b.Depletion >= b.RAW()
+}
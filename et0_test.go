@@ -0,0 +1,192 @@
+package weatherflow_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/tris/weatherflow"
+)
+
+func TestDailyET0(t *testing.T) {
+	// FAO-56 example 18: Uccle (Brussels), 6 July.
+	site := weatherflow.DeviceInfo{Latitude: 50.8, Elevation: 100}
+	in := weatherflow.ET0DailyInput{
+		Date:           time.Date(2023, 7, 6, 0, 0, 0, 0, time.UTC),
+		TMin:           12.3,
+		TMax:           21.5,
+		RHMin:          63,
+		RHMax:          84,
+		Wind:           weatherflow.WindAt2m(10/3.6, 10),
+		SolarRadiation: 22.07,
+	}
+	if got := weatherflow.DailyET0(site, in); math.Abs(got-3.9) > 0.05 {
+		t.Errorf("DailyET0 = %.2f, want 3.9", got)
+	}
+
+	// The measured pressure agrees with the estimate from the elevation.
+	in.Pressure = 1001
+	if got := weatherflow.DailyET0(site, in); math.Abs(got-3.9) > 0.05 {
+		t.Errorf("DailyET0 with pressure = %.2f, want 3.9", got)
+	}
+}
+
+func TestHourlyET0(t *testing.T) {
+	// FAO-56 example 19: N'Diaye (Senegal), 1 October.
+	site := weatherflow.DeviceInfo{Latitude: 16 + 13.0/60, Longitude: -(16 + 15.0/60), Elevation: 8}
+	tests := []struct {
+		name string
+		in   weatherflow.ET0HourlyInput
+		want float64
+	}{
+		{
+			name: "14-15h",
+			in: weatherflow.ET0HourlyInput{
+				Time:           time.Date(2023, 10, 1, 15, 0, 0, 0, time.UTC),
+				Temp:           38,
+				RH:             52,
+				Wind:           3.3,
+				SolarRadiation: 2.450,
+			},
+			want: 0.63,
+		},
+		{
+			name: "02-03h",
+			in: weatherflow.ET0HourlyInput{
+				Time: time.Date(2023, 10, 1, 3, 0, 0, 0, time.UTC),
+				Temp: 28,
+				RH:   90,
+				Wind: 1.9,
+			},
+			want: 0.0,
+		},
+	}
+
+	for _, test := range tests {
+		if got := weatherflow.HourlyET0(site, test.in); math.Abs(got-test.want) > 0.01 {
+			t.Errorf("%s: HourlyET0 = %.3f, want %.2f", test.name, got, test.want)
+		}
+	}
+}
+
+func TestWindAt2m(t *testing.T) {
+	// FAO-56 example 14: 3.2 m/s at 10 m is 2.4 m/s at 2 m.
+	if got := weatherflow.WindAt2m(3.2, 10); math.Abs(got-2.4) > 0.01 {
+		t.Errorf("WindAt2m(3.2, 10) = %.3f, want 2.4", got)
+	}
+	if got := weatherflow.WindAt2m(3.2, 0); got != 3.2 {
+		t.Errorf("WindAt2m(3.2, 0) = %v, want 3.2", got)
+	}
+}
+
+func TestET0Tracker(t *testing.T) {
+	site := weatherflow.DeviceInfo{Latitude: 40, Longitude: 0, Elevation: 200, Height: 2}
+
+	var hours []weatherflow.ET0Hour
+	var days []weatherflow.ET0Day
+	tracker := weatherflow.NewET0Tracker(1, site,
+		func(h weatherflow.ET0Hour) { hours = append(hours, h) },
+		func(d weatherflow.ET0Day) { days = append(days, d) },
+	)
+
+	// A clear summer day, observed every minute, with 3 mm of rain at noon.
+	start := time.Date(2023, 6, 21, 0, 0, 0, 0, time.UTC)
+	for m := 0; m <= 24*60; m++ {
+		hour := float64(m) / 60
+		obs := obsWith(20-6*math.Cos(2*math.Pi*(hour-3)/24), 60+20*math.Cos(2*math.Pi*(hour-3)/24), 990, 2)
+		obs.TimeEpoch = int(start.Unix()) + m*60
+		obs.SolarRadiation = int(math.Max(0, 950*math.Sin(math.Pi*(hour-5)/14)))
+		if m == 12*60 {
+			obs.RainAccumulated = 3
+		}
+		tracker.Handle(&weatherflow.MessageObsSt{DeviceID: 1, Obs: []weatherflow.ObsStData{obs}})
+	}
+	tracker.Handle(&weatherflow.MessageObsSt{DeviceID: 2, Obs: []weatherflow.ObsStData{{TimeEpoch: int(start.Unix()) + 48*3600}}})
+
+	if len(hours) != 24 {
+		t.Fatalf("got %d hours, want 24", len(hours))
+	}
+	if len(days) != 1 {
+		t.Fatalf("got %d days, want 1", len(days))
+	}
+
+	var sum, rain float64
+	for _, h := range hours {
+		sum += h.ET0
+		rain += h.Rain
+	}
+	if rain != 3 || days[0].Rain != 3 {
+		t.Errorf("rain = %v hourly, %v daily; want 3", rain, days[0].Rain)
+	}
+	if days[0].Date != "2023-06-21" {
+		t.Errorf("Date = %q, want 2023-06-21", days[0].Date)
+	}
+	// The hourly and daily equations should roughly agree.
+	if days[0].ET0 < 4 || math.Abs(sum-days[0].ET0) > 0.15*days[0].ET0 {
+		t.Errorf("daily ET0 = %.2f, sum of hourly = %.2f", days[0].ET0, sum)
+	}
+}
+
+func TestET0TrackerNight(t *testing.T) {
+	site := weatherflow.DeviceInfo{Latitude: 40, Longitude: 0, Elevation: 200, Height: 2}
+	start := time.Date(2023, 6, 21, 0, 0, 0, 0, time.UTC)
+
+	// nightET0 returns the ET0 of the hour from 22:00 after a day with the
+	// given fraction of clear-sky sunshine. Under the clouds that implies,
+	// less long-wave radiation is lost, leaving more energy to evaporate
+	// water.
+	nightET0 := func(sunshine float64) float64 {
+		var night float64
+		tracker := weatherflow.NewET0Tracker(1, site, func(h weatherflow.ET0Hour) {
+			if h.Start.Hour() == 22 {
+				night = h.ET0
+			}
+		}, nil)
+		for m := 0; m <= 23*60; m++ {
+			hour := float64(m) / 60
+			obs := obsWith(20, 60, 990, 2)
+			obs.TimeEpoch = int(start.Unix()) + m*60
+			obs.SolarRadiation = int(sunshine * math.Max(0, 950*math.Sin(math.Pi*(hour-5)/14)))
+			tracker.Add(obs)
+		}
+		return night
+	}
+
+	clear, overcast := nightET0(1), nightET0(0.3)
+	if overcast <= clear {
+		t.Errorf("night ET0 after a clear day = %.4f, after an overcast day = %.4f; want overcast > clear", clear, overcast)
+	}
+}
+
+func TestSoilWaterBalance(t *testing.T) {
+	b := weatherflow.SoilWaterBalance{TAW: 100}
+
+	// Five dry days at 5 mm deplete a quarter of the TAW.
+	for i := 0; i < 5; i++ {
+		if etc, _ := b.Step(0, 5); etc != 5 {
+			t.Errorf("day %d: ETc = %v, want 5", i, etc)
+		}
+	}
+	if b.Depletion != 25 || b.NeedsIrrigation() {
+		t.Errorf("after 5 days: depletion = %v, needs irrigation = %v; want 25, false", b.Depletion, b.NeedsIrrigation())
+	}
+
+	for i := 0; i < 5; i++ {
+		b.Step(0, 5)
+	}
+	if !b.NeedsIrrigation() {
+		t.Errorf("after 10 days: depletion = %v, want irrigation needed", b.Depletion)
+	}
+
+	// Once the depletion passes the RAW, the crop is stressed.
+	b.Step(0, 5)
+	if etc, _ := b.Step(0, 5); etc >= 5 {
+		t.Errorf("stressed ETc = %v, want < 5", etc)
+	}
+
+	// Heavy rain refills the root zone and drains the excess.
+	d := b.Depletion
+	if _, drainage := b.Step(80, 0); b.Depletion != 0 || math.Abs(drainage-(80-d)) > 1e-9 {
+		t.Errorf("after rain: depletion = %v, drainage = %v; want 0, %v", b.Depletion, drainage, 80-d)
+	}
+}
@@ -0,0 +1,200 @@
+package weatherflow
+
+// streamKey identifies one stream of observations from one device.
+type streamKey struct {
+	typ      string
+	deviceID int
+}
+
+// streamOf returns the stream a message belongs to and the timestamp of its
+// latest observation. ok is false for messages that carry no observations.
+func streamOf(m Message) (key streamKey, epoch int, ok bool) {
+	switch t := m.(type) {
+	case *MessageRapidWind:
+		return streamKey{t.Type, t.DeviceID}, t.Ob.TimeEpoch, true
+
+	case *MessageObsSt:
+		if len(t.Obs) == 0 {
+			return streamKey{}, 0, false
+		}
+		for _, obs := range t.Obs {
+			if obs.TimeEpoch > epoch {
+				epoch = obs.TimeEpoch
+			}
+		}
+		return streamKey{t.Type, t.DeviceID}, epoch, true
+	}
+
+	return streamKey{}, 0, false
+}
+
+// dedup drops observations and events that have already been delivered. It
+// only records what is delivered during one handover, so it stays small and
+// is never pruned.
+type dedup struct {
+	seen map[streamKey]map[dedupEntry]struct{}
+}
+
+// dedupEntry identifies an observation or event within its stream. Several
+// strikes can share a second, so they are told apart by distance and energy
+// too.
+type dedupEntry struct {
+	epoch    int
+	distance int
+	energy   int
+}
+
+func newDedup() *dedup {
+	return &dedup{seen: make(map[streamKey]map[dedupEntry]struct{})}
+}
+
+// filter returns m with any previously delivered observations or events
+// removed, or nil if nothing new remains. Other messages pass through. If
+// record is set, what remains is remembered as delivered.
+func (d *dedup) filter(m Message, record bool) Message {
+	switch t := m.(type) {
+	case *MessageRapidWind:
+		if !d.add(record, streamKey{t.Type, t.DeviceID}, dedupEntry{epoch: t.Ob.TimeEpoch}) {
+			return nil
+		}
+
+	case *MessageObsSt:
+		if len(t.Obs) == 0 {
+			return m
+		}
+
+		key := streamKey{t.Type, t.DeviceID}
+		fresh := t.Obs[:0]
+		for _, obs := range t.Obs {
+			if d.add(record, key, dedupEntry{epoch: obs.TimeEpoch}) {
+				fresh = append(fresh, obs)
+			}
+		}
+		if len(fresh) == 0 {
+			return nil
+		}
+		t.Obs = fresh
+
+	case *MessageEvtPrecip:
+		if !d.add(record, streamKey{t.Type, t.DeviceID}, dedupEntry{epoch: t.Evt.TimeEpoch}) {
+			return nil
+		}
+
+	case *MessageEvtStrike:
+		e := dedupEntry{epoch: t.Evt.TimeEpoch, distance: t.Evt.Distance, energy: t.Evt.Energy}
+		if !d.add(record, streamKey{t.Type, t.DeviceID}, e) {
+			return nil
+		}
+	}
+
+	return m
+}
+
+// add reports whether e is new for key, remembering it if record is set.
+func (d *dedup) add(record bool, key streamKey, e dedupEntry) bool {
+	if _, dup := d.seen[key][e]; dup {
+		return false
+	}
+	if record {
+		seen, ok := d.seen[key]
+		if !ok {
+			seen = make(map[dedupEntry]struct{})
+			d.seen[key] = seen
+		}
+		seen[e] = struct{}{}
+	}
+	return true
+}
+
+// handover switches delivery from an old connection to a new one stream by
+// stream. Observations from the new connection are held back until the old
+// connection has caught up with them, so that nothing is skipped and nothing
+// is delivered out of order, and anything both connections carried is
+// delivered once.
+type handover struct {
+	seen     *dedup
+	held     map[streamKey][]Message
+	switched map[streamKey]bool
+	carried  map[streamKey]bool
+}
+
+func newHandover() *handover {
+	return &handover{
+		seen:     newDedup(),
+		held:     make(map[streamKey][]Message),
+		switched: make(map[streamKey]bool),
+		carried:  make(map[streamKey]bool),
+	}
+}
+
+// fromOld returns the messages to deliver after m arrives on the old
+// connection.
+func (h *handover) fromOld(m Message) []Message {
+	key, epoch, ok := streamOf(m)
+	if !ok {
+		return h.fresh(nil, m)
+	}
+
+	h.carried[key] = true
+	if h.switched[key] {
+		return nil
+	}
+
+	out := h.fresh(nil, m)
+	if held := h.held[key]; len(held) > 0 {
+		if _, first, _ := streamOf(held[0]); epoch >= first {
+			// The streams overlap: hand this one over.
+			h.switched[key] = true
+			out = h.fresh(out, held...)
+			delete(h.held, key)
+		}
+	}
+	return out
+}
+
+// fromNew returns the messages to deliver after m arrives on the new
+// connection.
+func (h *handover) fromNew(m Message) []Message {
+	key, _, ok := streamOf(m)
+	if !ok || h.switched[key] {
+		return h.fresh(nil, m)
+	}
+
+	h.held[key] = append(h.held[key], m)
+	return nil
+}
+
+// done reports whether every stream carried by the old connection has been
+// handed over.
+func (h *handover) done() bool {
+	if len(h.carried) == 0 {
+		return false
+	}
+	for key := range h.carried {
+		if !h.switched[key] {
+			return false
+		}
+	}
+	return true
+}
+
+// flush returns everything still held back, for when the old connection
+// goes away before the handover completes.
+func (h *handover) flush() []Message {
+	var out []Message
+	for key, held := range h.held {
+		out = h.fresh(out, held...)
+		delete(h.held, key)
+	}
+	return out
+}
+
+// fresh appends to out those of msgs that haven't already been delivered.
+func (h *handover) fresh(out []Message, msgs ...Message) []Message {
+	for _, m := range msgs {
+		if m = h.seen.filter(m, true); m != nil {
+			out = append(out, m)
+		}
+	}
+	return out
+}
@@ -0,0 +1,524 @@
+package weatherflow
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Constants of the Liljegren et al. (2008) WBGT model, describing a
+// standard 2-inch black globe and natural wet bulb wick, and the ground.
+const (
+	globeDiameter     = 0.0508 // m
+	globeEmissivity   = 0.95
+	globeAlbedo       = 0.05
+	wickDiameter      = 0.007  // m
+	wickLength        = 0.0254 // m
+	wickEmissivity    = 0.95
+	wickAlbedo        = 0.4
+	surfaceEmissivity = 0.999
+	surfaceAlbedo     = 0.45
+	minWBGTWind       = 0.13 // m/s; the lowest speed the convection correlations hold for
+	maxNormalSolar    = 0.85 // highest plausible ratio of measured to top-of-atmosphere radiation
+	minCosZenith      = 0.00872654
+	stefanBoltzmann   = 5.6696e-8 // W/(m²·K⁴)
+	solarConstantW    = 1367      // W/m²
+	specificHeatAir   = 1003.5    // J/(kg·K)
+	molarMassAir      = 28.97
+	molarMassWater    = 18.015
+	wbgtTolerance     = 0.02 // K
+	wbgtIterations    = 50
+)
+
+// Heat stress flag thresholds, in °F of WBGT, from the US Army's TB MED 507.
+const (
+	heatRiskGreenF  = 80.0
+	heatRiskYellowF = 85.0
+	heatRiskRedF    = 88.0
+	heatRiskBlackF  = 90.0
+)
+
+const (
+	defaultHeatWindow     = 15 * time.Minute
+	defaultHeatHysteresis = 0.5
+	uvIndexIrradiance     = 0.025 // erythemally weighted W/m² per unit of UV index
+)
+
+// WBGTEstimate is an outdoor wet bulb globe temperature and the two
+// instrument temperatures it is made from, all in °C.
+type WBGTEstimate struct {
+	WBGT           float64
+	Globe          float64 // black globe temperature
+	NaturalWetBulb float64
+}
+
+// WBGT estimates the outdoor wet bulb globe temperature from AirTemperature,
+// RelativeHumidity, WindAvg, SolarRadiation and StationPressure, with the
+// model of Liljegren et al. (2008). site gives the latitude and longitude,
+// for the position of the sun, and the elevation and sensor height, for the
+// pressure if it is missing and to bring the wind speed to 2 m. It returns
+// false if the temperature or humidity is missing.
+func (obs ObsStData) WBGT(site DeviceInfo) (WBGTEstimate, bool) {
+	if obs.AirTemperature == nil || obs.RelativeHumidity == nil || *obs.RelativeHumidity <= 0 {
+		return WBGTEstimate{}, false
+	}
+
+	pressure := standardAtmospherePressure(site.BarometerElevation())
+	if obs.StationPressure != nil {
+		pressure = *obs.StationPressure
+	}
+	wind := math.Max(WindAt2m(obs.WindAvg, site.Height), minWBGTWind)
+
+	// Split the radiation into direct and diffuse parts, from how it
+	// compares with the radiation at the top of the atmosphere.
+	t := obs.Time()
+	phi := site.Latitude * math.Pi / 180
+	dr, decl := solarGeometry(t.UTC().YearDay())
+	cosZenith := math.Sin(phi)*math.Sin(decl) + math.Cos(phi)*math.Cos(decl)*math.Cos(solarHourAngle(t, site.Longitude))
+	solar := math.Max(float64(obs.SolarRadiation), 0)
+	var direct float64
+	if cosZenith >= minCosZenith && solar > 0 {
+		toa := solarConstantW * cosZenith * dr
+		normal := math.Min(solar/toa, maxNormalSolar)
+		solar = normal * toa
+		direct = math.Max(0, math.Min(math.Exp(3-1.34*normal-1.65/normal), 0.9))
+	}
+
+	ta := *obs.AirTemperature + zeroCelsius
+	eAir := *obs.RelativeHumidity / 100 * saturationVaporPressure(*obs.AirTemperature)
+	// Thermal radiation from the sky and the ground, which is taken to be at
+	// air temperature.
+	thermal := 0.5 * (0.575*math.Pow(eAir, 0.143)*math.Pow(ta, 4) + surfaceEmissivity*math.Pow(ta, 4))
+
+	globe := ta
+	for i := 0; i < wbgtIterations; i++ {
+		h := sphereHeatTransfer(0.5*(globe+ta), pressure, wind)
+		var beam float64
+		if direct > 0 {
+			beam = direct * (1/(2*cosZenith) - 1)
+		}
+		next := math.Pow(thermal-h/(stefanBoltzmann*globeEmissivity)*(globe-ta)+
+			solar/(2*stefanBoltzmann*globeEmissivity)*(1-globeAlbedo)*(beam+1+surfaceAlbedo), 0.25)
+		done := math.Abs(next-globe) < wbgtTolerance
+		globe = 0.9*globe + 0.1*next
+		if done {
+			break
+		}
+	}
+
+	dewPoint, _ := obs.DewPoint()
+	wetBulb := dewPoint + zeroCelsius
+	prandtl := specificHeatAir / (specificHeatAir + 1.25*gasConstantDryAir)
+	for i := 0; i < wbgtIterations; i++ {
+		ref := 0.5 * (wetBulb + ta)
+		h := cylinderHeatTransfer(ref, pressure, wind)
+		var beam float64
+		if direct > 0 {
+			beam = direct * math.Sqrt(1-cosZenith*cosZenith) / cosZenith / math.Pi
+		}
+		radiation := stefanBoltzmann*wickEmissivity*(thermal-math.Pow(wetBulb, 4)) +
+			(1-wickAlbedo)*solar*((1-direct)*(1+0.25*wickDiameter/wickLength)+beam+direct*0.25*wickDiameter/wickLength+surfaceAlbedo)
+		eWick := saturationVaporPressure(wetBulb - zeroCelsius)
+		density := pressure * 100 / (gasConstantDryAir * ref)
+		schmidt := airViscosity(ref) / (density * waterVapourDiffusivity(ref, pressure))
+		next := ta - latentHeat(ref)/(specificHeatAir*molarMassAir/molarMassWater)*
+			(eWick-eAir)/(pressure-eWick)*math.Pow(prandtl/schmidt, 0.56) + radiation/h
+		done := math.Abs(next-wetBulb) < wbgtTolerance
+		wetBulb = 0.9*wetBulb + 0.1*next
+		if done {
+			break
+		}
+	}
+
+	e := WBGTEstimate{
+		Globe:          globe - zeroCelsius,
+		NaturalWetBulb: wetBulb - zeroCelsius,
+	}
+	e.WBGT = 0.7*e.NaturalWetBulb + 0.2*e.Globe + 0.1**obs.AirTemperature
+	return e, true
+}
+
+// sphereHeatTransfer returns the convective heat transfer coefficient of the
+// globe, in W/(m²·K), at temperature t in K and pressure p in hPa.
+func sphereHeatTransfer(t, p, wind float64) float64 {
+	reynolds := wind * p * 100 / (gasConstantDryAir * t) * globeDiameter / airViscosity(t)
+	prandtl := specificHeatAir / (specificHeatAir + 1.25*gasConstantDryAir)
+	nusselt := 2 + 0.6*math.Sqrt(reynolds)*math.Pow(prandtl, 0.3333)
+	return nusselt * airThermalConductivity(t) / globeDiameter
+}
+
+// cylinderHeatTransfer returns the convective heat transfer coefficient of
+// the wick, in W/(m²·K), at temperature t in K and pressure p in hPa.
+func cylinderHeatTransfer(t, p, wind float64) float64 {
+	reynolds := wind * p * 100 / (gasConstantDryAir * t) * wickDiameter / airViscosity(t)
+	prandtl := specificHeatAir / (specificHeatAir + 1.25*gasConstantDryAir)
+	nusselt := 0.281 * math.Pow(reynolds, 0.6) * math.Pow(prandtl, 0.44)
+	return nusselt * airThermalConductivity(t) / wickDiameter
+}
+
+// airViscosity returns the dynamic viscosity of air at t K, in kg/(m·s).
+func airViscosity(t float64) float64 {
+	const sigma, epsilonK = 3.617, 97.0
+	omega := (t/epsilonK-2.9)/0.4*(-0.034) + 1.048
+	return 2.6693e-6 * math.Sqrt(molarMassAir*t) / (sigma * sigma * omega)
+}
+
+// airThermalConductivity returns the thermal conductivity of air at t K, in
+// W/(m·K).
+func airThermalConductivity(t float64) float64 {
+	return (specificHeatAir + 1.25*gasConstantDryAir) * airViscosity(t)
+}
+
+// waterVapourDiffusivity returns the diffusivity of water vapour in air at t
+// K and p hPa, in m²/s.
+func waterVapourDiffusivity(t, p float64) float64 {
+	pc := math.Cbrt(36.4 * 218)
+	tc := math.Pow(132*647.3, 5.0/12)
+	tc12 := math.Sqrt(132 * 647.3)
+	m := math.Sqrt(1/molarMassAir + 1/molarMassWater)
+	return 3.640e-4 * math.Pow(t/tc12, 2.334) * pc * tc * m / (p / 1013.25) * 1e-4
+}
+
+// latentHeat returns the heat of vaporisation of water at t K, in J/kg.
+func latentHeat(t float64) float64 {
+	return (313.15-t)/30*(-71100) + 2.4073e6
+}
+
+// HeatRisk is a heat stress flag condition, from the WBGT, as used by the
+// US military and many outdoor employers to set work/rest cycles and water
+// intake.
+type HeatRisk int
+
+const (
+	HeatRiskNone   HeatRisk = iota // below 80 °F (26.7 °C)
+	HeatRiskGreen                  // 80 to 85 °F (26.7 to 29.4 °C)
+	HeatRiskYellow                 // 85 to 88 °F (29.4 to 31.1 °C)
+	HeatRiskRed                    // 88 to 90 °F (31.1 to 32.2 °C)
+	HeatRiskBlack                  // 90 °F (32.2 °C) or more
+)
+
+func (r HeatRisk) String() string {
+	switch r {
+	case HeatRiskNone:
+		return "none"
+	case HeatRiskGreen:
+		return "green"
+	case HeatRiskYellow:
+		return "yellow"
+	case HeatRiskRed:
+		return "red"
+	case HeatRiskBlack:
+		return "black"
+	default:
+		return fmt.Sprintf("HeatRisk(%d)", int(r))
+	}
+}
+
+// ClassifyWBGT returns the flag condition for a WBGT in °C.
+func ClassifyWBGT(wbgt float64) HeatRisk {
+	f := celsiusToFahrenheit(wbgt)
+	switch {
+	case f < heatRiskGreenF:
+		return HeatRiskNone
+	case f < heatRiskYellowF:
+		return HeatRiskGreen
+	case f < heatRiskRedF:
+		return HeatRiskYellow
+	case f < heatRiskBlackF:
+		return HeatRiskRed
+	default:
+		return HeatRiskBlack
+	}
+}
+
+// UVCategory is the World Health Organization's exposure category for a UV
+// index.
+type UVCategory int
+
+const (
+	UVLow      UVCategory = iota // 0 to 2
+	UVModerate                   // 3 to 5
+	UVHigh                       // 6 and 7
+	UVVeryHigh                   // 8 to 10
+	UVExtreme                    // 11 and above
+)
+
+func (c UVCategory) String() string {
+	switch c {
+	case UVLow:
+		return "low"
+	case UVModerate:
+		return "moderate"
+	case UVHigh:
+		return "high"
+	case UVVeryHigh:
+		return "very high"
+	case UVExtreme:
+		return "extreme"
+	default:
+		return fmt.Sprintf("UVCategory(%d)", int(c))
+	}
+}
+
+// ClassifyUV returns the category of a UV index, rounded to the nearest
+// whole number as it is reported to the public.
+func ClassifyUV(uv float64) UVCategory {
+	switch i := math.Round(uv); {
+	case i < 3:
+		return UVLow
+	case i < 6:
+		return UVModerate
+	case i < 8:
+		return UVHigh
+	case i < 11:
+		return UVVeryHigh
+	default:
+		return UVExtreme
+	}
+}
+
+// SkinType is a Fitzpatrick skin phototype, from I (always burns, never
+// tans) to VI (never burns). The zero value is the most sensitive type.
+type SkinType int
+
+const (
+	SkinTypeI SkinType = iota
+	SkinTypeII
+	SkinTypeIII
+	SkinTypeIV
+	SkinTypeV
+	SkinTypeVI
+)
+
+func (s SkinType) String() string {
+	switch s {
+	case SkinTypeI:
+		return "I"
+	case SkinTypeII:
+		return "II"
+	case SkinTypeIII:
+		return "III"
+	case SkinTypeIV:
+		return "IV"
+	case SkinTypeV:
+		return "V"
+	case SkinTypeVI:
+		return "VI"
+	default:
+		return fmt.Sprintf("SkinType(%d)", int(s))
+	}
+}
+
+// minimalErythemalDose is the UV dose, in J/m² (erythemally weighted), that
+// reddens each skin type.
+var minimalErythemalDose = [...]float64{200, 250, 350, 450, 600, 1000}
+
+// SafeExposure estimates how long unprotected skin of the given type can be
+// exposed at a UV index before it starts to burn. It returns false if the
+// index is zero, when there is no limit, or the skin type is unknown.
+func SafeExposure(uv float64, skin SkinType) (time.Duration, bool) {
+	if uv <= 0 || skin < 0 || int(skin) >= len(minimalErythemalDose) {
+		return 0, false
+	}
+	seconds := minimalErythemalDose[skin] / (uv * uvIndexIrradiance)
+	return time.Duration(seconds * float64(time.Second)).Round(time.Minute), true
+}
+
+// HeatEvent reports a change in the heat stress flag or UV category at a
+// device.
+type HeatEvent struct {
+	DeviceID       int
+	Time           time.Time
+	WBGT           float64 // °C, averaged over the window
+	UV             float64 // averaged over the window
+	HeatRisk       HeatRisk
+	PrevHeatRisk   HeatRisk
+	UVCategory     UVCategory
+	PrevUVCategory UVCategory
+}
+
+// HeatStatus is the current heat stress and UV situation at a device.
+type HeatStatus struct {
+	Time       time.Time // of the latest observation
+	WBGT       float64   // °C, averaged over the window
+	UV         float64   // averaged over the window
+	HeatRisk   HeatRisk
+	UVCategory UVCategory
+}
+
+// HeatConfig configures a HeatTracker. Zero values select the defaults.
+type HeatConfig struct {
+	// DeviceInfo returns a device's location, elevation and sensor height,
+	// which the WBGT estimate needs; typically Client.DeviceInfo. If it is
+	// nil, devices are assumed to be at sea level on the equator, which
+	// misplaces the sun.
+	DeviceInfo func(deviceID int) DeviceInfo
+
+	// Window is how long WBGT and UV are averaged over, to smooth out
+	// passing clouds and gusts. The default is 15 minutes.
+	Window time.Duration
+
+	// Hysteresis is how far, in °C of WBGT or units of UV index, a reading
+	// must fall below a threshold before the level drops, so that readings
+	// hovering around it don't cause a stream of events. The default is
+	// 0.5.
+	Hysteresis float64
+}
+
+// HeatTracker follows the heat stress flag and UV category at each device,
+// from obs_st observations, and calls its callback when either changes. It
+// is safe for concurrent use.
+type HeatTracker struct {
+	cfg     HeatConfig
+	onEvent func(HeatEvent)
+	devices map[int]*heatDevice
+	mu      sync.Mutex
+}
+
+// heatDevice is the state of one device.
+type heatDevice struct {
+	samples []heatSample // ordered by time
+	now     time.Time
+	risk    HeatRisk
+	uv      UVCategory
+}
+
+type heatSample struct {
+	time time.Time
+	wbgt float64
+	uv   float64
+}
+
+// NewHeatTracker creates a HeatTracker that passes changes to onEvent.
+// onEvent is called synchronously from the method that caused the event.
+func NewHeatTracker(cfg HeatConfig, onEvent func(HeatEvent)) *HeatTracker {
+	if cfg.DeviceInfo == nil {
+		cfg.DeviceInfo = func(int) DeviceInfo { return DeviceInfo{} }
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = defaultHeatWindow
+	}
+	if cfg.Hysteresis <= 0 {
+		cfg.Hysteresis = defaultHeatHysteresis
+	}
+	return &HeatTracker{
+		cfg:     cfg,
+		onEvent: onEvent,
+		devices: make(map[int]*heatDevice),
+	}
+}
+
+// Handle records the observations in an obs_st message. Other messages are
+// ignored.
+func (h *HeatTracker) Handle(msg Message) {
+	if m, ok := msg.(*MessageObsSt); ok {
+		for _, obs := range m.Obs {
+			h.Add(m.DeviceID, obs)
+		}
+	}
+}
+
+// Add records an observation. Observations without temperature or humidity
+// are skipped.
+func (h *HeatTracker) Add(deviceID int, obs ObsStData) {
+	e, ok := obs.WBGT(h.cfg.DeviceInfo(deviceID))
+	if !ok {
+		return
+	}
+	s := heatSample{time: obs.Time(), wbgt: e.WBGT, uv: obs.UV}
+
+	h.mu.Lock()
+	d, ok := h.devices[deviceID]
+	if !ok {
+		d = &heatDevice{}
+		h.devices[deviceID] = d
+	}
+
+	i := sort.Search(len(d.samples), func(i int) bool { return d.samples[i].time.After(s.time) })
+	d.samples = append(d.samples, heatSample{})
+	copy(d.samples[i+1:], d.samples[i:])
+	d.samples[i] = s
+
+	if s.time.After(d.now) {
+		d.now = s.time
+	}
+	cutoff := d.now.Add(-h.cfg.Window)
+	drop := 0
+	for drop < len(d.samples) && !d.samples[drop].time.After(cutoff) {
+		drop++
+	}
+	d.samples = d.samples[drop:]
+
+	wbgt, uv := d.mean()
+	event := HeatEvent{
+		DeviceID:       deviceID,
+		Time:           d.now,
+		WBGT:           wbgt,
+		UV:             uv,
+		PrevHeatRisk:   d.risk,
+		PrevUVCategory: d.uv,
+	}
+	d.risk = heatLevel(d.risk, wbgt, h.cfg.Hysteresis)
+	d.uv = uvLevel(d.uv, uv, h.cfg.Hysteresis)
+	event.HeatRisk, event.UVCategory = d.risk, d.uv
+	h.mu.Unlock()
+
+	if h.onEvent != nil && (event.HeatRisk != event.PrevHeatRisk || event.UVCategory != event.PrevUVCategory) {
+		h.onEvent(event)
+	}
+}
+
+// Status returns the current situation at a device, or false if it hasn't
+// reported.
+func (h *HeatTracker) Status(deviceID int) (HeatStatus, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	d, ok := h.devices[deviceID]
+	if !ok || len(d.samples) == 0 {
+		return HeatStatus{}, false
+	}
+	wbgt, uv := d.mean()
+	return HeatStatus{
+		Time:       d.now,
+		WBGT:       wbgt,
+		UV:         uv,
+		HeatRisk:   d.risk,
+		UVCategory: d.uv,
+	}, true
+}
+
+// mean returns the mean WBGT and UV index over the window.
+func (d *heatDevice) mean() (wbgt, uv float64) {
+	for _, s := range d.samples {
+		wbgt += s.wbgt
+		uv += s.uv
+	}
+	n := float64(len(d.samples))
+	return wbgt / n, uv / n
+}
+
+// heatLevel returns the flag condition for wbgt, holding the current one
+// until the reading is hysteresis below its threshold.
+func heatLevel(current HeatRisk, wbgt, hysteresis float64) HeatRisk {
+	if r := ClassifyWBGT(wbgt); r >= current {
+		return r
+	}
+	if r := ClassifyWBGT(wbgt + hysteresis); r < current {
+		return r
+	}
+	return current
+}
+
+// uvLevel is like heatLevel, for the UV category.
+func uvLevel(current UVCategory, uv, hysteresis float64) UVCategory {
+	if c := ClassifyUV(uv); c >= current {
+		return c
+	}
+	if c := ClassifyUV(uv + hysteresis); c < current {
+		return c
+	}
+	return current
+}
@@ -0,0 +1,159 @@
+package weatherflow_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tris/weatherflow"
+)
+
+func TestWBGT(t *testing.T) {
+	// Phoenix, at local noon in July.
+	site := weatherflow.DeviceInfo{Latitude: 33.4, Longitude: -112, Elevation: 340, Height: 2}
+	noon := int(time.Date(2023, 7, 15, 19, 0, 0, 0, time.UTC).Unix())
+	obs := func(temp, humidity, wind float64, solar int) weatherflow.ObsStData {
+		o := obsWith(temp, humidity, 970, wind)
+		o.TimeEpoch = noon
+		o.SolarRadiation = solar
+		return o
+	}
+
+	tests := []struct {
+		name       string
+		obs        weatherflow.ObsStData
+		wantMin    float64
+		wantMax    float64
+		globeAbove float64 // minimum globe temperature above the air
+	}{
+		{name: "sunny, dry", obs: obs(35, 30, 2, 900), wantMin: 29, wantMax: 31, globeAbove: 10},
+		{name: "sunny, humid, light wind", obs: obs(30, 70, 1, 800), wantMin: 31.5, wantMax: 33.5, globeAbove: 10},
+		{name: "shade", obs: obs(35, 30, 2, 0), wantMin: 24, wantMax: 26, globeAbove: -2},
+	}
+
+	for _, test := range tests {
+		got, ok := test.obs.WBGT(site)
+		if !ok {
+			t.Errorf("%s: WBGT returned false", test.name)
+			continue
+		}
+		if got.WBGT < test.wantMin || got.WBGT > test.wantMax {
+			t.Errorf("%s: WBGT = %.2f, want %.1f to %.1f", test.name, got.WBGT, test.wantMin, test.wantMax)
+		}
+		if got.Globe-*test.obs.AirTemperature < test.globeAbove {
+			t.Errorf("%s: globe temperature = %.2f, want at least %.1f above the air", test.name, got.Globe, test.globeAbove)
+		}
+		if got.NaturalWetBulb > *test.obs.AirTemperature {
+			t.Errorf("%s: natural wet bulb = %.2f, above the air temperature", test.name, got.NaturalWetBulb)
+		}
+	}
+
+	// Wind cools the globe, and so lowers the WBGT, in the sun.
+	calm, _ := obs(35, 30, 0.5, 900).WBGT(site)
+	windy, _ := obs(35, 30, 5, 900).WBGT(site)
+	if calm.WBGT <= windy.WBGT {
+		t.Errorf("WBGT calm = %.2f, windy = %.2f; want calm > windy", calm.WBGT, windy.WBGT)
+	}
+
+	if _, ok := (weatherflow.ObsStData{AirTemperature: float64Ptr(30)}).WBGT(site); ok {
+		t.Error("WBGT without humidity: expected ok = false")
+	}
+}
+
+func TestClassifyWBGT(t *testing.T) {
+	tests := []struct {
+		wbgt float64
+		want weatherflow.HeatRisk
+	}{
+		{25, weatherflow.HeatRiskNone},
+		{26.7, weatherflow.HeatRiskGreen},
+		{30, weatherflow.HeatRiskYellow},
+		{31.5, weatherflow.HeatRiskRed},
+		{32.3, weatherflow.HeatRiskBlack},
+	}
+	for _, test := range tests {
+		if got := weatherflow.ClassifyWBGT(test.wbgt); got != test.want {
+			t.Errorf("ClassifyWBGT(%v) = %v, want %v", test.wbgt, got, test.want)
+		}
+	}
+}
+
+func TestUV(t *testing.T) {
+	tests := []struct {
+		uv   float64
+		want weatherflow.UVCategory
+	}{
+		{0, weatherflow.UVLow},
+		{2.4, weatherflow.UVLow},
+		{2.5, weatherflow.UVModerate},
+		{6, weatherflow.UVHigh},
+		{10.4, weatherflow.UVVeryHigh},
+		{12, weatherflow.UVExtreme},
+	}
+	for _, test := range tests {
+		if got := weatherflow.ClassifyUV(test.uv); got != test.want {
+			t.Errorf("ClassifyUV(%v) = %v, want %v", test.uv, got, test.want)
+		}
+	}
+
+	if got, ok := weatherflow.SafeExposure(10, weatherflow.SkinTypeII); !ok || got != 17*time.Minute {
+		t.Errorf("SafeExposure(10, II) = %v, %v; want 17m, true", got, ok)
+	}
+	if got, ok := weatherflow.SafeExposure(5, weatherflow.SkinTypeVI); !ok || got != 133*time.Minute {
+		t.Errorf("SafeExposure(5, VI) = %v, %v; want 2h13m, true", got, ok)
+	}
+	if _, ok := weatherflow.SafeExposure(0, weatherflow.SkinTypeI); ok {
+		t.Error("SafeExposure(0): expected ok = false")
+	}
+}
+
+func TestHeatTracker(t *testing.T) {
+	const start = 1689440400
+	var events []weatherflow.HeatEvent
+	tracker := weatherflow.NewHeatTracker(weatherflow.HeatConfig{Window: time.Minute}, func(e weatherflow.HeatEvent) {
+		events = append(events, e)
+	})
+
+	// In the shade, so the WBGT follows the temperature and humidity.
+	add := func(minute int, temp, uv float64) {
+		o := obsWith(temp, 60, 1000, 2)
+		o.TimeEpoch = start + minute*60
+		o.UV = uv
+		tracker.Handle(&weatherflow.MessageObsSt{DeviceID: 1, Obs: []weatherflow.ObsStData{o}})
+	}
+
+	add(0, 25, 1)
+	add(1, 33, 7) // green, high UV
+	add(2, 31, 7) // just below green, within the hysteresis
+	add(3, 38, 7) // black
+	add(4, 25, 1) // back to none and low
+
+	want := []struct {
+		risk weatherflow.HeatRisk
+		uv   weatherflow.UVCategory
+	}{
+		{weatherflow.HeatRiskGreen, weatherflow.UVHigh},
+		{weatherflow.HeatRiskBlack, weatherflow.UVHigh},
+		{weatherflow.HeatRiskNone, weatherflow.UVLow},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(events), len(want), events)
+	}
+	for i, w := range want {
+		if events[i].HeatRisk != w.risk || events[i].UVCategory != w.uv {
+			t.Errorf("event %d = %v, %v; want %v, %v", i, events[i].HeatRisk, events[i].UVCategory, w.risk, w.uv)
+		}
+	}
+	if events[2].PrevHeatRisk != weatherflow.HeatRiskBlack || events[2].PrevUVCategory != weatherflow.UVHigh {
+		t.Errorf("event 2 previous = %v, %v; want black, high", events[2].PrevHeatRisk, events[2].PrevUVCategory)
+	}
+
+	got, ok := tracker.Status(1)
+	wantStatus := weatherflow.HeatStatus{Time: time.Unix(start+4*60, 0), WBGT: got.WBGT, UV: 1}
+	if !ok || !cmp.Equal(got, wantStatus) {
+		t.Errorf("Status mismatch (-want +got):\n%s", cmp.Diff(wantStatus, got))
+	}
+	if _, ok := tracker.Status(2); ok {
+		t.Error("Status(2): expected ok = false")
+	}
+}
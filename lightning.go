@@ -0,0 +1,370 @@
+package weatherflow
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWarningDistance = 10.0 // km; roughly the 30-second flash-to-bang distance
+	defaultQuietPeriod     = 30 * time.Minute
+	defaultTrendWindow     = 30 * time.Minute
+	lightningHistory       = time.Hour // how long strikes are kept for Count
+	trendMinStrikes        = 3
+	trendMinSpan           = 2 * time.Minute
+	trendStationary        = 0.1 // km/min; slower movement counts as stationary
+)
+
+// StormTrend is the direction a thunderstorm is moving relative to a device,
+// judged from the distances of recent strikes.
+type StormTrend int
+
+const (
+	StormUnknown     StormTrend = iota // too few recent strikes to tell
+	StormApproaching                   // strikes are getting closer
+	StormStationary                    // strikes are staying at about the same distance
+	StormReceding                      // strikes are getting further away
+)
+
+func (t StormTrend) String() string {
+	switch t {
+	case StormUnknown:
+		return "unknown"
+	case StormApproaching:
+		return "approaching"
+	case StormStationary:
+		return "stationary"
+	case StormReceding:
+		return "receding"
+	default:
+		return fmt.Sprintf("StormTrend(%d)", int(t))
+	}
+}
+
+// LightningEventType distinguishes lightning warnings from all-clears.
+type LightningEventType int
+
+const (
+	LightningWarning  LightningEventType = iota // a strike within the warning distance
+	LightningAllClear                           // no such strike for the quiet period
+)
+
+func (t LightningEventType) String() string {
+	switch t {
+	case LightningWarning:
+		return "warning"
+	case LightningAllClear:
+		return "all clear"
+	default:
+		return fmt.Sprintf("LightningEventType(%d)", int(t))
+	}
+}
+
+// LightningEvent reports the start or end of a lightning warning at a
+// device.
+type LightningEvent struct {
+	Type     LightningEventType
+	DeviceID int
+	Time     time.Time // when the warning started or the all-clear was given
+	// LastStrike and Distance describe the strike that started the warning,
+	// or the last close strike before the all-clear.
+	LastStrike time.Time
+	Distance   float64 // km
+}
+
+// LightningStatus is the current lightning situation at a device.
+type LightningStatus struct {
+	Warning      bool
+	LastStrike   time.Time // zero if no strike has been seen
+	LastDistance float64   // km
+	Trend        StormTrend
+}
+
+// LightningConfig configures a LightningTracker. Zero values select the
+// defaults.
+type LightningConfig struct {
+	// WarningDistance is the distance, in km, within which a strike starts
+	// a warning. The default is 10 km.
+	WarningDistance float64
+
+	// QuietPeriod is how long after the last strike within the warning
+	// distance the all-clear is given. The default is 30 minutes, as in the
+	// 30-30 rule.
+	QuietPeriod time.Duration
+
+	// TrendWindow is how far back strikes are considered when judging
+	// whether a storm is approaching or receding. The default is 30
+	// minutes.
+	TrendWindow time.Duration
+}
+
+// LightningTracker follows lightning at each device, from evt_strike
+// messages and the strike counts in obs_st observations, and calls its
+// callback when a warning starts and when the all-clear is given. It is safe
+// for concurrent use.
+type LightningTracker struct {
+	cfg     LightningConfig
+	onEvent func(LightningEvent)
+	devices map[int]*lightningDevice
+	mu      sync.Mutex
+}
+
+// lightningDevice is the state of one device.
+type lightningDevice struct {
+	strikes   []strike // ordered by time
+	now       time.Time
+	warning   bool
+	lastClose strike
+}
+
+type strike struct {
+	time     time.Time
+	distance float64
+	event    bool // from evt_strike, rather than an observation's count
+}
+
+// NewLightningTracker creates a LightningTracker that passes warnings and
+// all-clears to onEvent. onEvent is called synchronously from the method
+// that caused the event.
+func NewLightningTracker(cfg LightningConfig, onEvent func(LightningEvent)) *LightningTracker {
+	if cfg.WarningDistance <= 0 {
+		cfg.WarningDistance = defaultWarningDistance
+	}
+	if cfg.QuietPeriod <= 0 {
+		cfg.QuietPeriod = defaultQuietPeriod
+	}
+	if cfg.TrendWindow <= 0 {
+		cfg.TrendWindow = defaultTrendWindow
+	}
+	return &LightningTracker{
+		cfg:     cfg,
+		onEvent: onEvent,
+		devices: make(map[int]*lightningDevice),
+	}
+}
+
+// Handle records an evt_strike or obs_st message. Other messages are
+// ignored.
+func (l *LightningTracker) Handle(msg Message) {
+	switch m := msg.(type) {
+	case *MessageEvtStrike:
+		l.AddStrike(m.DeviceID, m.Evt.Time(), float64(m.Evt.Distance))
+	case *MessageObsSt:
+		for _, obs := range m.Obs {
+			l.AddObsSt(m.DeviceID, obs)
+		}
+	}
+}
+
+// AddStrike records a single strike at the given distance, in km.
+func (l *LightningTracker) AddStrike(deviceID int, at time.Time, distance float64) {
+	l.mu.Lock()
+	d := l.device(deviceID)
+	events := l.advance(deviceID, d, at)
+	events = append(events, l.add(deviceID, d, strike{time: at, distance: distance, event: true})...)
+	l.mu.Unlock()
+
+	l.emit(events)
+}
+
+// AddObsSt records the strikes counted in an observation. Strikes already
+// reported by evt_strike during the observation's interval aren't counted
+// again. The observation also moves the device's clock on, which may give
+// the all-clear.
+func (l *LightningTracker) AddObsSt(deviceID int, obs ObsStData) {
+	interval := time.Duration(obs.ReportInterval) * time.Minute
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	at := obs.Time()
+
+	l.mu.Lock()
+	d := l.device(deviceID)
+	events := l.advance(deviceID, d, at)
+
+	known := 0
+	for _, s := range d.strikes {
+		if s.event && s.time.After(at.Add(-interval)) && !s.time.After(at) {
+			known++
+		}
+	}
+	for i := known; i < obs.LightningStrikeCount; i++ {
+		events = append(events, l.add(deviceID, d, strike{time: at, distance: float64(obs.LightningStrikeAvgDistance)})...)
+	}
+	l.mu.Unlock()
+
+	l.emit(events)
+}
+
+// Expire gives the all-clear for any device whose quiet period has passed
+// as of now, for devices that have stopped reporting.
+func (l *LightningTracker) Expire(now time.Time) {
+	l.mu.Lock()
+	var events []LightningEvent
+	for id, d := range l.devices {
+		events = append(events, l.advance(id, d, now)...)
+	}
+	l.mu.Unlock()
+
+	l.emit(events)
+}
+
+// Status returns the current lightning situation at a device.
+func (l *LightningTracker) Status(deviceID int) LightningStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	d, ok := l.devices[deviceID]
+	if !ok {
+		return LightningStatus{}
+	}
+
+	status := LightningStatus{
+		Warning: d.warning,
+		Trend:   l.trend(d),
+	}
+	if n := len(d.strikes); n > 0 {
+		status.LastStrike = d.strikes[n-1].time
+		status.LastDistance = d.strikes[n-1].distance
+	}
+	return status
+}
+
+// Count returns the number of strikes at a device in the window up to its
+// latest message, up to an hour.
+func (l *LightningTracker) Count(deviceID int, window time.Duration) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	d, ok := l.devices[deviceID]
+	if !ok {
+		return 0
+	}
+
+	cutoff := d.now.Add(-window)
+	n := 0
+	for _, s := range d.strikes {
+		if s.time.After(cutoff) {
+			n++
+		}
+	}
+	return n
+}
+
+// device returns the state of a device, creating it if necessary. l.mu must
+// be held.
+func (l *LightningTracker) device(id int) *lightningDevice {
+	d, ok := l.devices[id]
+	if !ok {
+		d = &lightningDevice{}
+		l.devices[id] = d
+	}
+	return d
+}
+
+// add records a strike, starting a warning if it is close enough. l.mu must
+// be held.
+func (l *LightningTracker) add(deviceID int, d *lightningDevice, s strike) []LightningEvent {
+	i := sort.Search(len(d.strikes), func(i int) bool { return d.strikes[i].time.After(s.time) })
+	d.strikes = append(d.strikes, strike{})
+	copy(d.strikes[i+1:], d.strikes[i:])
+	d.strikes[i] = s
+
+	if s.distance > l.cfg.WarningDistance {
+		return nil
+	}
+	if s.time.After(d.lastClose.time) {
+		d.lastClose = s
+	}
+	if d.warning || d.now.Sub(d.lastClose.time) >= l.cfg.QuietPeriod {
+		return nil
+	}
+
+	d.warning = true
+	return []LightningEvent{{
+		Type:       LightningWarning,
+		DeviceID:   deviceID,
+		Time:       s.time,
+		LastStrike: s.time,
+		Distance:   s.distance,
+	}}
+}
+
+// advance moves a device's clock on to now, forgetting old strikes and
+// giving the all-clear if the quiet period has passed. l.mu must be held.
+func (l *LightningTracker) advance(deviceID int, d *lightningDevice, now time.Time) []LightningEvent {
+	if now.After(d.now) {
+		d.now = now
+	}
+
+	keep := lightningHistory
+	if l.cfg.TrendWindow > keep {
+		keep = l.cfg.TrendWindow
+	}
+	cutoff := d.now.Add(-keep)
+	drop := 0
+	for drop < len(d.strikes) && !d.strikes[drop].time.After(cutoff) {
+		drop++
+	}
+	d.strikes = d.strikes[drop:]
+
+	if !d.warning || d.now.Sub(d.lastClose.time) < l.cfg.QuietPeriod {
+		return nil
+	}
+
+	d.warning = false
+	return []LightningEvent{{
+		Type:       LightningAllClear,
+		DeviceID:   deviceID,
+		Time:       d.lastClose.time.Add(l.cfg.QuietPeriod),
+		LastStrike: d.lastClose.time,
+		Distance:   d.lastClose.distance,
+	}}
+}
+
+// trend judges the storm's movement from the least-squares slope of strike
+// distance against time over the trend window. l.mu must be held.
+func (l *LightningTracker) trend(d *lightningDevice) StormTrend {
+	cutoff := d.now.Add(-l.cfg.TrendWindow)
+	var recent []strike
+	for _, s := range d.strikes {
+		if s.time.After(cutoff) {
+			recent = append(recent, s)
+		}
+	}
+	if len(recent) < trendMinStrikes || recent[len(recent)-1].time.Sub(recent[0].time) < trendMinSpan {
+		return StormUnknown
+	}
+
+	var sumT, sumD, sumTT, sumTD float64
+	for _, s := range recent {
+		t := s.time.Sub(recent[0].time).Minutes()
+		sumT += t
+		sumD += s.distance
+		sumTT += t * t
+		sumTD += t * s.distance
+	}
+	n := float64(len(recent))
+	slope := (n*sumTD - sumT*sumD) / (n*sumTT - sumT*sumT) // km/min
+
+	switch {
+	case math.IsNaN(slope) || math.Abs(slope) < trendStationary:
+		return StormStationary
+	case slope < 0:
+		return StormApproaching
+	default:
+		return StormReceding
+	}
+}
+
+func (l *LightningTracker) emit(events []LightningEvent) {
+	if l.onEvent == nil {
+		return
+	}
+	for _, e := range events {
+		l.onEvent(e)
+	}
+}
@@ -0,0 +1,112 @@
+package weatherflow_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tris/weatherflow"
+)
+
+func TestLightningTracker(t *testing.T) {
+	const start = 1681700000
+	at := func(minute int) time.Time { return time.Unix(start+int64(minute)*60, 0) }
+
+	var events []weatherflow.LightningEvent
+	tracker := weatherflow.NewLightningTracker(weatherflow.LightningConfig{}, func(e weatherflow.LightningEvent) {
+		events = append(events, e)
+	})
+
+	strike := func(minute, distance int) {
+		tracker.Handle(&weatherflow.MessageEvtStrike{DeviceID: 1, Evt: weatherflow.EvtStrikeData{TimeEpoch: start + minute*60, Distance: distance}})
+	}
+	quiet := func(minute int) {
+		tracker.Handle(&weatherflow.MessageObsSt{DeviceID: 1, Obs: []weatherflow.ObsStData{{TimeEpoch: start + minute*60, ReportInterval: 1}}})
+	}
+
+	// A storm approaches from 26 km, closing 3 km every two minutes.
+	for i := 0; i < 6; i++ {
+		strike(2*i, 26-3*i)
+	}
+	if len(events) != 0 {
+		t.Fatalf("got %d events while the storm was distant, want 0", len(events))
+	}
+	if got := tracker.Status(1); got.Warning || got.Trend != weatherflow.StormApproaching || got.LastDistance != 11 {
+		t.Errorf("Status while distant = %+v, want approaching at 11 km, no warning", got)
+	}
+
+	strike(12, 7) // within 10 km: warning
+	strike(14, 5) // no second warning
+	if got := tracker.Count(1, 5*time.Minute); got != 3 {
+		t.Errorf("Count(5m) = %d, want 3", got)
+	}
+	if got := tracker.Count(1, time.Hour); got != 8 {
+		t.Errorf("Count(1h) = %d, want 8", got)
+	}
+
+	// The storm moves away, with one last close strike at minute 20.
+	strike(20, 9)
+	strike(24, 15)
+	strike(28, 20)
+	strike(32, 25)
+	strike(36, 30)
+	if got := tracker.Status(1).Trend; got != weatherflow.StormReceding {
+		t.Errorf("Trend while moving away = %v, want receding", got)
+	}
+
+	for m := 37; m < 50; m++ {
+		quiet(m)
+	}
+	if !tracker.Status(1).Warning {
+		t.Error("warning ended before the quiet period passed")
+	}
+	quiet(50)
+	if tracker.Status(1).Warning {
+		t.Error("warning still in effect after the quiet period")
+	}
+
+	want := []weatherflow.LightningEvent{
+		{Type: weatherflow.LightningWarning, DeviceID: 1, Time: at(12), LastStrike: at(12), Distance: 7},
+		{Type: weatherflow.LightningAllClear, DeviceID: 1, Time: at(50), LastStrike: at(20), Distance: 9},
+	}
+	if diff := cmp.Diff(want, events); diff != "" {
+		t.Errorf("events mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLightningTrackerObsSt(t *testing.T) {
+	const start = 1681700000
+
+	var events []weatherflow.LightningEvent
+	tracker := weatherflow.NewLightningTracker(weatherflow.LightningConfig{WarningDistance: 5, QuietPeriod: 10 * time.Minute}, func(e weatherflow.LightningEvent) {
+		events = append(events, e)
+	})
+
+	// One of the two strikes counted by the observation was also reported
+	// as an event, so only one more is added.
+	tracker.AddStrike(1, time.Unix(start+30, 0), 12)
+	tracker.AddObsSt(1, weatherflow.ObsStData{TimeEpoch: start + 60, ReportInterval: 1, LightningStrikeCount: 2, LightningStrikeAvgDistance: 4})
+	if got := tracker.Count(1, time.Hour); got != 2 {
+		t.Errorf("Count = %d, want 2", got)
+	}
+	if len(events) != 1 || events[0].Type != weatherflow.LightningWarning || events[0].Distance != 4 {
+		t.Errorf("events = %+v, want one warning at 4 km", events)
+	}
+	if got := tracker.Status(1).Trend; got != weatherflow.StormUnknown {
+		t.Errorf("Trend with two strikes = %v, want unknown", got)
+	}
+
+	// A device that stops reporting gets its all-clear from Expire.
+	tracker.Expire(time.Unix(start+60, 0).Add(9 * time.Minute))
+	if len(events) != 1 {
+		t.Fatalf("got %d events before the quiet period passed, want 1", len(events))
+	}
+	tracker.Expire(time.Unix(start+60, 0).Add(10 * time.Minute))
+	if len(events) != 2 || events[1].Type != weatherflow.LightningAllClear {
+		t.Errorf("events = %+v, want a warning then an all-clear", events)
+	}
+
+	if got := tracker.Status(2); got != (weatherflow.LightningStatus{}) {
+		t.Errorf("Status of unknown device = %+v, want zero", got)
+	}
+}
@@ -0,0 +1,351 @@
+package weatherflow
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultMaxDevicesPerClient = 100
+
+// AccountMessage is a Message along with the account it was received for.
+type AccountMessage struct {
+	Account string
+	Message
+}
+
+// Manager runs a Client for each of several accounts, each with its own
+// token, and merges their messages into a single callback. Large device sets
+// are spread over several connections per account.
+type Manager struct {
+	accounts    map[string]*account // keyed by token
+	timeout     *time.Duration
+	logf        Logf
+	maxDevices  int
+	onNewClient func(*Client)
+	msgs        chan AccountMessage
+	started     bool
+	ctx         context.Context
+	cancel      context.CancelFunc
+	mu          sync.Mutex
+}
+
+// account is the set of Clients sharing one token.
+type account struct {
+	name    atomic.Value // string; read from Client goroutines
+	token   string
+	clients []*Client
+	devices map[int]*Client
+}
+
+// NewManager creates a new Manager. The optional connection timeout and log
+// function are passed to each Client it creates.
+func NewManager(timeout *time.Duration, logf Logf) *Manager {
+	if logf == nil {
+		logf = func(format string, args ...interface{}) {} // discard
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Manager{
+		accounts:   make(map[string]*account),
+		timeout:    timeout,
+		logf:       logf,
+		maxDevices: defaultMaxDevicesPerClient,
+		msgs:       make(chan AccountMessage, 100),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// SetMaxDevicesPerClient sets how many devices share one connection before
+// another connection is opened for the same account.
+func (m *Manager) SetMaxDevicesPerClient(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxDevices = n
+}
+
+// OnNewClient registers f to be called with each Client the Manager creates,
+// before it is started, so it can be configured (e.g. with SetStaleTimeout).
+// The Manager hooks each Client's OnDeviceError and OnStateChange to keep
+// track of its devices; hooks that f sets are called after the Manager's, but
+// they mustn't be replaced once f returns.
+func (m *Manager) OnNewClient(f func(*Client)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onNewClient = f
+}
+
+// AddAccount registers a token under a name, which is attached to every
+// message received with it. Accounts are also created implicitly by
+// AddDevice, named after a redacted form of the token.
+func (m *Manager) AddAccount(token, name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.account(token).name.Store(name)
+}
+
+// RemoveAccount stops all connections for a token.
+func (m *Manager) RemoveAccount(token string) {
+	m.mu.Lock()
+	a, ok := m.accounts[token]
+	if !ok {
+		m.mu.Unlock()
+		return
+	}
+	clients := a.clients
+	a.clients = nil
+	delete(m.accounts, token)
+	m.mu.Unlock()
+
+	// Stopping a Client calls its state hook, which may need m.mu.
+	for _, c := range clients {
+		c.Stop()
+	}
+}
+
+// AddDevice subscribes to a device using the given token.
+func (m *Manager) AddDevice(token string, id int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	a := m.account(token)
+	if _, ok := a.devices[id]; ok {
+		return
+	}
+
+	// Use the least loaded connection, unless they're all full.
+	var client *Client
+	for _, c := range a.clients {
+		if n := c.DeviceCount(); n < m.maxDevices && (client == nil || n < client.DeviceCount()) {
+			client = c
+		}
+	}
+	if client == nil {
+		client = m.newClient(a)
+	}
+
+	client.AddDevice(id)
+	a.devices[id] = client
+}
+
+// RemoveDevice unsubscribes from a device using the given token. A
+// connection left with no devices is closed.
+func (m *Manager) RemoveDevice(token string, id int) {
+	m.mu.Lock()
+	a, ok := m.accounts[token]
+	if !ok {
+		m.mu.Unlock()
+		return
+	}
+	client, ok := a.devices[id]
+	if !ok {
+		m.mu.Unlock()
+		return
+	}
+
+	client.RemoveDevice(id)
+	delete(a.devices, id)
+	empty := client.DeviceCount() == 0 && a.removeClient(client)
+	m.mu.Unlock()
+
+	// Stopping client calls its state hook, which may need m.mu.
+	if empty {
+		client.Stop()
+	}
+}
+
+// DeviceCount returns a count of monitored devices across all accounts.
+func (m *Manager) DeviceCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := 0
+	for _, a := range m.accounts {
+		n += len(a.devices)
+	}
+	return n
+}
+
+// ClientCount returns the number of connections the Manager is running.
+func (m *Manager) ClientCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := 0
+	for _, a := range m.accounts {
+		n += len(a.clients)
+	}
+	return n
+}
+
+// Start starts all Clients, and any created later, and passes their
+// messages to onMessage one at a time, in the order they arrive.
+func (m *Manager) Start(onMessage func(AccountMessage)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.started = true
+
+	go func() {
+		for {
+			select {
+			case <-m.ctx.Done():
+				return
+			case msg := <-m.msgs:
+				onMessage(msg)
+			}
+		}
+	}()
+
+	for _, a := range m.accounts {
+		for _, c := range a.clients {
+			m.startClient(a, c)
+		}
+	}
+}
+
+// Stop stops all Clients.
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	var clients []*Client
+	for _, a := range m.accounts {
+		clients = append(clients, a.clients...)
+	}
+	m.mu.Unlock()
+
+	// Stopping a Client calls its state hook, which may need m.mu.
+	for _, c := range clients {
+		c.Stop()
+	}
+	m.cancel()
+}
+
+// account returns the account for token, creating it if necessary. m.mu
+// must be held.
+func (m *Manager) account(token string) *account {
+	a, ok := m.accounts[token]
+	if !ok {
+		a = &account{
+			token:   token,
+			devices: make(map[int]*Client),
+		}
+		a.name.Store(accountName(token))
+		m.accounts[token] = a
+	}
+	return a
+}
+
+// newClient adds a Client to a, starting it if the Manager is running. m.mu
+// must be held.
+func (m *Manager) newClient(a *account) *Client {
+	c := NewClient(a.token, m.timeout, func(format string, args ...interface{}) {
+		m.logf("[%s] "+format, append([]interface{}{a.name.Load()}, args...)...)
+	})
+	if m.onNewClient != nil {
+		m.onNewClient(c)
+	}
+	m.watch(a, c)
+
+	a.clients = append(a.clients, c)
+	if m.started {
+		m.startClient(a, c)
+	}
+	return c
+}
+
+// watch hooks c so that the Manager hears when it drops a device by itself
+// (see SetRemoveDeniedDevices) or gives up because its token was rejected,
+// chaining any hooks already set.
+func (m *Manager) watch(a *account, c *Client) {
+	c.mu.Lock()
+	onDeviceError := c.onDeviceError
+	c.onDeviceError = func(err *DeviceError) {
+		m.deviceDropped(a, c, err.DeviceID)
+		if onDeviceError != nil {
+			onDeviceError(err)
+		}
+	}
+	c.mu.Unlock()
+
+	c.stateMu.Lock()
+	onStateChange := c.onStateChange
+	c.onStateChange = func(s State) {
+		var authErr *AuthError
+		if s.Conn == StateStopped && errors.As(s.LastError, &authErr) {
+			m.clientFailed(a, c)
+		}
+		if onStateChange != nil {
+			onStateChange(s)
+		}
+	}
+	c.stateMu.Unlock()
+}
+
+// deviceDropped forgets a device if c is no longer subscribed to it, and
+// closes c if that leaves it with no devices.
+func (m *Manager) deviceDropped(a *account, c *Client, id int) {
+	if c.hasDevice(id) {
+		return
+	}
+
+	m.mu.Lock()
+	if a.devices[id] == c {
+		delete(a.devices, id)
+	}
+	empty := c.DeviceCount() == 0 && a.removeClient(c)
+	m.mu.Unlock()
+
+	// Stopping c calls its state hook, which may need m.mu.
+	if empty {
+		c.Stop()
+	}
+}
+
+// clientFailed forgets c, which has stopped for good, and its devices.
+func (m *Manager) clientFailed(a *account, c *Client) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !a.removeClient(c) {
+		return
+	}
+	for id, dc := range a.devices {
+		if dc == c {
+			delete(a.devices, id)
+		}
+	}
+}
+
+// startClient starts c, forwarding its messages to the Manager's callback.
+func (m *Manager) startClient(a *account, c *Client) {
+	c.Start(func(msg Message) {
+		select {
+		case m.msgs <- AccountMessage{Account: a.name.Load().(string), Message: msg}:
+		case <-m.ctx.Done():
+		}
+	})
+}
+
+// removeClient removes c from a's clients, reporting whether it was there.
+func (a *account) removeClient(c *Client) bool {
+	for i, ac := range a.clients {
+		if ac == c {
+			a.clients = append(a.clients[:i], a.clients[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// accountName returns a name for an account that doesn't give away its
+// token.
+func accountName(token string) string {
+	if len(token) <= 4 {
+		return redacted
+	}
+	return "..." + token[len(token)-4:]
+}
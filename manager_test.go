@@ -0,0 +1,255 @@
+package weatherflow_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tris/weatherflow"
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+// deviceEchoHandler sends one rapid_wind message for each device subscribed
+// to, tagged with the token the connection was made with.
+func deviceEchoHandler(w http.ResponseWriter, r *http.Request) {
+	c, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer c.Close(websocket.StatusInternalError, "Internal error")
+
+	token := r.URL.Query().Get("token")
+	if err := wsjson.Write(r.Context(), c, map[string]string{"type": "connection_opened"}); err != nil {
+		return
+	}
+
+	for {
+		var msg map[string]interface{}
+		if err := wsjson.Read(r.Context(), c, &msg); err != nil {
+			return
+		}
+		if msg["type"] == "listen_rapid_start" {
+			_ = wsjson.Write(r.Context(), c, map[string]interface{}{
+				"type":          "rapid_wind",
+				"device_id":     msg["device_id"],
+				"serial_number": token,
+				"ob":            []interface{}{1681768025, 4.27, 282},
+			})
+		}
+	}
+}
+
+func TestManager(t *testing.T) {
+	url, stopServer := startMockServerWithHandler(deviceEchoHandler)
+	defer stopServer()
+
+	manager := weatherflow.NewManager(nil, t.Logf)
+	manager.SetMaxDevicesPerClient(2)
+	manager.OnNewClient(func(c *weatherflow.Client) {
+		c.SetURL(url)
+	})
+	manager.AddAccount("token_a", "Account A")
+	manager.AddAccount("token_b", "Account B")
+
+	devices := map[string][]int{
+		"token_a": {1, 2, 3, 4, 5},
+		"token_b": {6},
+	}
+	for token, ids := range devices {
+		for _, id := range ids {
+			manager.AddDevice(token, id)
+		}
+	}
+
+	if n := manager.DeviceCount(); n != 6 {
+		t.Errorf("DeviceCount() = %d, want 6", n)
+	}
+	// Five devices at two per connection, plus one.
+	if n := manager.ClientCount(); n != 4 {
+		t.Errorf("ClientCount() = %d, want 4", n)
+	}
+
+	msgCh := make(chan weatherflow.AccountMessage, 10)
+	manager.Start(func(msg weatherflow.AccountMessage) {
+		msgCh <- msg
+	})
+	defer manager.Stop()
+
+	wantAccount := map[int]string{1: "Account A", 2: "Account A", 3: "Account A", 4: "Account A", 5: "Account A", 6: "Account B"}
+	wantToken := map[string]string{"Account A": "token_a", "Account B": "token_b"}
+	for len(wantAccount) > 0 {
+		select {
+		case msg := <-msgCh:
+			rw, ok := msg.Message.(*weatherflow.MessageRapidWind)
+			if !ok {
+				t.Fatalf("Unexpected message: %#v", msg.Message)
+			}
+			if want, ok := wantAccount[rw.DeviceID]; !ok || msg.Account != want {
+				t.Errorf("Device %d arrived for account %q, want %q", rw.DeviceID, msg.Account, want)
+			}
+			if rw.SerialNumber != wantToken[msg.Account] {
+				t.Errorf("Device %d was subscribed with token %q, want %q", rw.DeviceID, rw.SerialNumber, wantToken[msg.Account])
+			}
+			delete(wantAccount, rw.DeviceID)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for devices %v", wantAccount)
+		}
+	}
+
+	// Emptying a connection closes it.
+	manager.RemoveDevice("token_b", 6)
+	if n := manager.ClientCount(); n != 3 {
+		t.Errorf("ClientCount() after removing device = %d, want 3", n)
+	}
+}
+
+func TestManagerDroppedDevices(t *testing.T) {
+	// Device 2 is denied; any other is accepted. Token "bad_token" is
+	// rejected outright.
+	url, stopServer := startMockServerWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("token") == "bad_token" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		c, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close(websocket.StatusInternalError, "Internal error")
+
+		if err := wsjson.Write(r.Context(), c, map[string]string{"type": "connection_opened"}); err != nil {
+			return
+		}
+		for {
+			var msg map[string]interface{}
+			if err := wsjson.Read(r.Context(), c, &msg); err != nil {
+				return
+			}
+			if msg["type"] == "listen_start" && msg["device_id"] == float64(2) {
+				_ = wsjson.Write(r.Context(), c, map[string]interface{}{
+					"status":    map[string]interface{}{"status_code": 2, "status_message": "NOT AUTHORIZED"},
+					"device_id": msg["device_id"],
+					"type":      "obs_st",
+				})
+			}
+		}
+	})
+	defer stopServer()
+
+	logf, stopLog := testLogf(t)
+	defer stopLog()
+
+	deviceErrs := make(chan *weatherflow.DeviceError, 10)
+	stopped := make(chan struct{}, 10)
+	manager := weatherflow.NewManager(nil, logf)
+	manager.SetMaxDevicesPerClient(1)
+	manager.OnNewClient(func(c *weatherflow.Client) {
+		c.SetURL(url)
+		c.SetRemoveDeniedDevices(true)
+		c.OnDeviceError(func(err *weatherflow.DeviceError) {
+			deviceErrs <- err
+		})
+		c.OnStateChange(func(s weatherflow.State) {
+			if s.Conn == weatherflow.StateStopped {
+				stopped <- struct{}{}
+			}
+		})
+	})
+	manager.AddDevice("good_token", 1)
+	manager.AddDevice("good_token", 2)
+	manager.Start(func(msg weatherflow.AccountMessage) {})
+	defer manager.Stop()
+
+	// The Manager's hooks run before those set in OnNewClient.
+	select {
+	case err := <-deviceErrs:
+		if err.DeviceID != 2 {
+			t.Fatalf("Got device error for device %d, want 2", err.DeviceID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for device error")
+	}
+	if n := manager.DeviceCount(); n != 1 {
+		t.Errorf("DeviceCount() after denied device = %d, want 1", n)
+	}
+	if n := manager.ClientCount(); n != 1 {
+		t.Errorf("ClientCount() after denied device = %d, want 1", n)
+	}
+
+	// Drain the stop of the emptied connection.
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the emptied connection to stop")
+	}
+
+	manager.AddDevice("bad_token", 3)
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the rejected connection to stop")
+	}
+	if n := manager.DeviceCount(); n != 1 {
+		t.Errorf("DeviceCount() after rejected token = %d, want 1", n)
+	}
+	if n := manager.ClientCount(); n != 1 {
+		t.Errorf("ClientCount() after rejected token = %d, want 1", n)
+	}
+}
+
+func TestManagerStopDuringAuthFailure(t *testing.T) {
+	url, stopServer := startMockServerWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	})
+	defer stopServer()
+
+	// Hold the Client between recording the rejection and stopping itself,
+	// so that the Manager stops it first.
+	givingUp := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	testLog, stopLog := testLogf(t)
+	defer stopLog()
+	logf := func(format string, args ...interface{}) {
+		msg := fmt.Sprintf(format, args...)
+		testLog("%s", msg)
+		if strings.Contains(msg, "Giving up") {
+			once.Do(func() { close(givingUp) })
+			<-release
+		}
+	}
+
+	manager := weatherflow.NewManager(nil, logf)
+	manager.OnNewClient(func(c *weatherflow.Client) {
+		c.SetURL(url)
+	})
+	manager.AddDevice("bad_token", 1)
+	manager.Start(func(msg weatherflow.AccountMessage) {})
+
+	select {
+	case <-givingUp:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the token to be rejected")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		manager.Stop()
+		close(stopped)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for Stop")
+	}
+	if n := manager.ClientCount(); n != 0 {
+		t.Errorf("ClientCount() after Stop = %d, want 0", n)
+	}
+}
@@ -1,16 +1,65 @@
 package weatherflow
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Message interface {
 	GetType() string
 	GetDeviceID() (int, bool)
+	GetMeta() *Meta
+}
+
+// Origin identifies where a message came from.
+type Origin int
+
+const (
+	OriginUnknown   Origin = iota
+	OriginWebSocket        // live from the WeatherFlow WebSocket API
+	OriginCache            // the server's cached copy, sent on subscribing
+	OriginUDP              // a hub's local UDP broadcast
+	OriginReplay           // replayed from storage
+)
+
+func (o Origin) String() string {
+	switch o {
+	case OriginUnknown:
+		return "unknown"
+	case OriginWebSocket:
+		return "websocket"
+	case OriginCache:
+		return "cache"
+	case OriginUDP:
+		return "udp"
+	case OriginReplay:
+		return "replay"
+	default:
+		return fmt.Sprintf("Origin(%d)", int(o))
+	}
+}
+
+// Meta records how and when a message was received. It is embedded in every
+// message type.
+type Meta struct {
+	Raw        []byte // the message exactly as received
+	ReceivedAt time.Time
+	Origin     Origin
+}
+
+// GetMeta returns the message's receipt metadata.
+func (m *Meta) GetMeta() *Meta {
+	return m
 }
 
 type MessageObsSt struct {
+	Meta `json:"-"`
+
 	Status   ObsStStatus  `json:"status"`
 	DeviceID int          `json:"device_id"`
 	Type     string       `json:"type"`
@@ -20,6 +69,8 @@ type MessageObsSt struct {
 }
 
 type MessageRapidWind struct {
+	Meta `json:"-"`
+
 	DeviceID     int           `json:"device_id"`
 	SerialNumber string        `json:"serial_number"`
 	Type         string        `json:"type"`
@@ -27,58 +78,124 @@ type MessageRapidWind struct {
 	Ob           RapidWindData `json:"ob"`
 }
 
+// MessageEvtPrecip reports that a device has detected the start of rain.
+type MessageEvtPrecip struct {
+	Meta `json:"-"`
+
+	DeviceID     int           `json:"device_id"`
+	SerialNumber string        `json:"serial_number"`
+	Type         string        `json:"type"`
+	HubSN        string        `json:"hub_sn"`
+	Source       string        `json:"source"`
+	Evt          EvtPrecipData `json:"evt"`
+}
+
+// MessageEvtStrike reports a lightning strike detected by a device.
+type MessageEvtStrike struct {
+	Meta `json:"-"`
+
+	DeviceID     int           `json:"device_id"`
+	SerialNumber string        `json:"serial_number"`
+	Type         string        `json:"type"`
+	HubSN        string        `json:"hub_sn"`
+	Source       string        `json:"source"`
+	Evt          EvtStrikeData `json:"evt"`
+}
+
 type MessageConnectionOpened struct {
+	Meta `json:"-"`
+
 	Type string `json:"type"`
 }
 
 type MessageAck struct {
+	Meta `json:"-"`
+
 	ID   string `json:"id"`
 	Type string `json:"type"`
 }
 
+// MessageError is sent by the server when it can't satisfy a request, such
+// as a subscription to a device the token isn't permitted to access.
+type MessageError struct {
+	Meta `json:"-"`
+
+	ID       string      `json:"id"`
+	Type     string      `json:"type"`
+	DeviceID int         `json:"device_id"`
+	Status   ObsStStatus `json:"status"`
+}
+
 type ObsStStatus struct {
 	StatusCode    int    `json:"status_code"`
 	StatusMessage string `json:"status_message"`
 }
 
+// OK reports whether the status indicates success.
+func (s ObsStStatus) OK() bool {
+	return s.StatusCode == 0
+}
+
 type ObsStSummary struct {
-	PressureTrend                  string  `json:"pressure_trend"`
-	StrikeCount1h                  int     `json:"strike_count_1h"`
-	StrikeCount3h                  int     `json:"strike_count_3h"`
-	PrecipTotal1h                  float64 `json:"precip_total_1h"`
-	StrikeLastDist                 int     `json:"strike_last_dist"`
-	StrikeLastEpoch                int     `json:"strike_last_epoch"`
-	PrecipAccumLocalYesterday      float64 `json:"precip_accum_local_yesterday"`
-	PrecipAccumLocalYesterdayFinal float64 `json:"precip_accum_local_yesterday_final"`
-	PrecipAnalysisTypeYesterday    int     `json:"precip_analysis_type_yesterday"`
-	RainingMinutes                 []int   `json:"raining_minutes"`
-	PrecipMinutesLocalDay          int     `json:"precip_minutes_local_day"`
-	PrecipMinutesLocalYesterday    int     `json:"precip_minutes_local_yesterday"`
+	PressureTrend                    PressureTrend             `json:"pressure_trend"`
+	StrikeCount1h                    int                       `json:"strike_count_1h"`
+	StrikeCount3h                    int                       `json:"strike_count_3h"`
+	PrecipTotal1h                    float64                   `json:"precip_total_1h"`
+	StrikeLastDist                   int                       `json:"strike_last_dist"`
+	StrikeLastEpoch                  int                       `json:"strike_last_epoch"`
+	PrecipAccumLocalYesterday        float64                   `json:"precip_accum_local_yesterday"`
+	PrecipAccumLocalYesterdayFinal   float64                   `json:"precip_accum_local_yesterday_final"`
+	PrecipAnalysisTypeYesterday      PrecipitationAnalysisType `json:"precip_analysis_type_yesterday"`
+	FeelsLike                        *float64                  `json:"feels_like,omitempty"`
+	HeatIndex                        *float64                  `json:"heat_index,omitempty"`
+	WindChill                        *float64                  `json:"wind_chill,omitempty"`
+	DewPoint                         *float64                  `json:"dew_point,omitempty"`
+	WetBulbTemperature               *float64                  `json:"wet_bulb_temperature,omitempty"`
+	WetBulbGlobeTemperature          *float64                  `json:"wet_bulb_globe_temperature,omitempty"`
+	DeltaT                           *float64                  `json:"delta_t,omitempty"`
+	AirDensity                       *float64                  `json:"air_density,omitempty"`
+	RainingMinutes                   []int                     `json:"raining_minutes"`
+	PrecipMinutesLocalDay            int                       `json:"precip_minutes_local_day"`
+	PrecipMinutesLocalYesterday      int                       `json:"precip_minutes_local_yesterday"`
+	PrecipMinutesLocalYesterdayFinal int                       `json:"precip_minutes_local_yesterday_final"`
+	PulseAdjObTime                   int                       `json:"pulse_adj_ob_time,omitempty"`
+	PulseAdjObWindAvg                *float64                  `json:"pulse_adj_ob_wind_avg,omitempty"`
+	PulseAdjObTemp                   *float64                  `json:"pulse_adj_ob_temp,omitempty"`
+
+	// Extras holds any fields not listed above, so that values added to the
+	// API later aren't lost.
+	Extras map[string]json.RawMessage `json:"-"`
 }
 
 type ObsStData struct {
-	TimeEpoch                       int      `json:"time_epoch"`
-	WindLull                        float64  `json:"wind_lull"`
-	WindAvg                         float64  `json:"wind_avg"`
-	WindGust                        float64  `json:"wind_gust"`
-	WindDirection                   int      `json:"wind_direction"`
-	WindSampleInterval              int      `json:"wind_sample_interval"`
-	StationPressure                 *float64 `json:"station_pressure"`
-	AirTemperature                  *float64 `json:"air_temperature"`
-	RelativeHumidity                *float64 `json:"relative_humidity"`
-	Illuminance                     int      `json:"illuminance"`
-	UV                              int      `json:"uv"`
-	SolarRadiation                  int      `json:"solar_radiation"`
-	RainAccumulated                 float64  `json:"rain_accumulated"`
-	PrecipitationType               int      `json:"precipitation_type"`
-	LightningStrikeAvgDistance      int      `json:"lightning_strike_avg_distance"`
-	LightningStrikeCount            int      `json:"lightning_strike_count"`
-	Battery                         float64  `json:"battery"`
-	ReportInterval                  int      `json:"report_interval"`
-	LocalDailyRainAccumulation      float64  `json:"local_daily_rain_accumulation"`
-	RainAccumulatedFinal            float64  `json:"rain_accumulated_final"`
-	LocalDailyRainAccumulationFinal float64  `json:"local_daily_rain_accumulation_final"`
-	PrecipitationAnalysisType       int      `json:"precipitation_analysis_type"`
+	TimeEpoch                       int                       `json:"time_epoch"`
+	WindLull                        float64                   `json:"wind_lull"`
+	WindAvg                         float64                   `json:"wind_avg"`
+	WindGust                        float64                   `json:"wind_gust"`
+	WindDirection                   int                       `json:"wind_direction"`
+	WindSampleInterval              int                       `json:"wind_sample_interval"`
+	StationPressure                 *float64                  `json:"station_pressure"`
+	AirTemperature                  *float64                  `json:"air_temperature"`
+	RelativeHumidity                *float64                  `json:"relative_humidity"`
+	Illuminance                     int                       `json:"illuminance"`
+	UV                              float64                   `json:"uv"`
+	SolarRadiation                  int                       `json:"solar_radiation"`
+	RainAccumulated                 float64                   `json:"rain_accumulated"`
+	PrecipitationType               PrecipitationType         `json:"precipitation_type"`
+	LightningStrikeAvgDistance      int                       `json:"lightning_strike_avg_distance"`
+	LightningStrikeCount            int                       `json:"lightning_strike_count"`
+	Battery                         float64                   `json:"battery"`
+	ReportInterval                  int                       `json:"report_interval"`
+	LocalDailyRainAccumulation      float64                   `json:"local_daily_rain_accumulation"`
+	RainAccumulatedFinal            float64                   `json:"rain_accumulated_final"`
+	LocalDailyRainAccumulationFinal float64                   `json:"local_daily_rain_accumulation_final"`
+	PrecipitationAnalysisType       PrecipitationAnalysisType `json:"precipitation_analysis_type"`
+
+	// Missing has bit 1<<i set if position i of the wire array was null,
+	// for the fields above that can't be nil themselves, such as those of a
+	// failed wind or light sensor. They read as zero, and MarshalJSON
+	// writes them back as null.
+	Missing uint32 `json:"-"`
 }
 
 type RapidWindData struct {
@@ -87,66 +204,333 @@ type RapidWindData struct {
 	WindDirection int     `json:"wind_direction"`
 }
 
+type EvtPrecipData struct {
+	TimeEpoch int `json:"time_epoch"`
+}
+
+type EvtStrikeData struct {
+	TimeEpoch int `json:"time_epoch"`
+	Distance  int `json:"distance"` // km
+	Energy    int `json:"energy"`
+}
+
+// StrikeLastAt returns the time of the last lightning strike, or the zero
+// time if none has been recorded.
+func (s ObsStSummary) StrikeLastAt() time.Time {
+	return epochTime(s.StrikeLastEpoch)
+}
+
+// PulseAdjObAt returns the time of the observation used for the pulse
+// adjusted values, or the zero time if there isn't one.
+func (s ObsStSummary) PulseAdjObAt() time.Time {
+	return epochTime(s.PulseAdjObTime)
+}
+
+// obsStSummaryFields lists the JSON names of the fields ObsStSummary decodes
+// itself.
+var obsStSummaryFields = jsonFieldNames(reflect.TypeOf(ObsStSummary{}))
+
+func (s *ObsStSummary) UnmarshalJSON(data []byte) error {
+	type summary ObsStSummary // without this method, to avoid recursion
+	if err := json.Unmarshal(data, (*summary)(s)); err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for name := range obsStSummaryFields {
+		delete(fields, name)
+	}
+
+	s.Extras = nil
+	if len(fields) > 0 {
+		s.Extras = fields
+	}
+	return nil
+}
+
+// MarshalJSON encodes s, including any Extras, as it appears on the wire.
+func (s ObsStSummary) MarshalJSON() ([]byte, error) {
+	type summary ObsStSummary // without this method, to avoid recursion
+	data, err := json.Marshal(summary(s))
+	if err != nil || len(s.Extras) == 0 {
+		return data, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for name, value := range s.Extras {
+		if _, known := fields[name]; !known {
+			fields[name] = value
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// jsonFieldNames returns the JSON names of a struct type's fields.
+func jsonFieldNames(t reflect.Type) map[string]struct{} {
+	names := make(map[string]struct{})
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("json")
+		name, _, _ := strings.Cut(tag, ",")
+		if name != "" && name != "-" {
+			names[name] = struct{}{}
+		}
+	}
+	return names
+}
+
 func (obs *ObsStData) UnmarshalJSON(data []byte) error {
-	var obsArray []interface{}
+	// Accept the named-field form produced by MarshalNamedJSON too.
+	if isJSONObject(data) {
+		return json.Unmarshal(data, (*obsStDataNamed)(obs))
+	}
+
+	var obsArray []*float64
 	err := json.Unmarshal(data, &obsArray)
 	if err != nil {
 		return err
 	}
+	if len(obsArray) < 22 {
+		return fmt.Errorf("obs_st observation has %d fields, want 22", len(obsArray))
+	}
+
+	obs.Missing = 0
+	value := func(i int) float64 {
+		if obsArray[i] == nil {
+			obs.Missing |= 1 << i
+			return 0
+		}
+		return *obsArray[i]
+	}
+
+	obs.TimeEpoch = int(value(0))
+	obs.WindLull = value(1)
+	obs.WindAvg = value(2)
+	obs.WindGust = value(3)
+	obs.WindDirection = int(value(4))
+	obs.WindSampleInterval = int(value(5))
+	obs.StationPressure = obsArray[6]
+	obs.AirTemperature = obsArray[7]
+	obs.RelativeHumidity = obsArray[8]
+	obs.Illuminance = int(value(9))
+	obs.UV = value(10)
+	obs.SolarRadiation = int(value(11))
+	obs.RainAccumulated = value(12)
+	obs.PrecipitationType = PrecipitationType(value(13))
+	obs.LightningStrikeAvgDistance = int(value(14))
+	obs.LightningStrikeCount = int(value(15))
+	obs.Battery = value(16)
+	obs.ReportInterval = int(value(17))
+	obs.LocalDailyRainAccumulation = value(18)
+	obs.RainAccumulatedFinal = value(19)
+	obs.LocalDailyRainAccumulationFinal = value(20)
+	obs.PrecipitationAnalysisType = PrecipitationAnalysisType(value(21))
+
+	return nil
+}
+
+// Time returns the time of the observation.
+func (obs ObsStData) Time() time.Time {
+	return time.Unix(int64(obs.TimeEpoch), 0)
+}
+
+// MarshalJSON encodes obs in the positional array form used on the wire,
+// with null for missing sensor readings.
+func (obs ObsStData) MarshalJSON() ([]byte, error) {
+	fields := []interface{}{
+		obs.TimeEpoch,
+		obs.WindLull,
+		obs.WindAvg,
+		obs.WindGust,
+		obs.WindDirection,
+		obs.WindSampleInterval,
+		obs.StationPressure,
+		obs.AirTemperature,
+		obs.RelativeHumidity,
+		obs.Illuminance,
+		obs.UV,
+		obs.SolarRadiation,
+		obs.RainAccumulated,
+		obs.PrecipitationType,
+		obs.LightningStrikeAvgDistance,
+		obs.LightningStrikeCount,
+		obs.Battery,
+		obs.ReportInterval,
+		obs.LocalDailyRainAccumulation,
+		obs.RainAccumulatedFinal,
+		obs.LocalDailyRainAccumulationFinal,
+		obs.PrecipitationAnalysisType,
+	}
+	for i := range fields {
+		if obs.Missing&(1<<i) != 0 {
+			fields[i] = nil
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// obsStDataNamed has ObsStData's fields but not its methods, so it encodes
+// as an object keyed by the field tags.
+type obsStDataNamed ObsStData
 
-	obs.TimeEpoch = int(obsArray[0].(float64))
-	obs.WindLull = obsArray[1].(float64)
-	obs.WindAvg = obsArray[2].(float64)
-	obs.WindGust = obsArray[3].(float64)
-	obs.WindDirection = int(obsArray[4].(float64))
-	obs.WindSampleInterval = int(obsArray[5].(float64))
+// MarshalNamedJSON encodes obs as an object keyed by field name, which is
+// easier to read than the wire format. UnmarshalJSON accepts either form.
+func (obs ObsStData) MarshalNamedJSON() ([]byte, error) {
+	return json.Marshal(obsStDataNamed(obs))
+}
 
-	if obsArray[6] != nil {
-		staPressure := obsArray[6].(float64)
-		obs.StationPressure = &staPressure
+func (rw *RapidWindData) UnmarshalJSON(data []byte) error {
+	// Accept the named-field form produced by MarshalNamedJSON too.
+	if isJSONObject(data) {
+		return json.Unmarshal(data, (*rapidWindDataNamed)(rw))
 	}
 
-	if obsArray[7] != nil {
-		airTemp := obsArray[7].(float64)
-		obs.AirTemperature = &airTemp
+	var rwArray []*float64
+	err := json.Unmarshal(data, &rwArray)
+	if err != nil {
+		return err
+	}
+	if len(rwArray) < 3 {
+		return fmt.Errorf("rapid_wind observation has %d fields, want 3", len(rwArray))
 	}
 
-	if obsArray[8] != nil {
-		relHumidity := obsArray[8].(float64)
-		obs.RelativeHumidity = &relHumidity
+	value := func(i int) float64 {
+		if rwArray[i] == nil {
+			return 0
+		}
+		return *rwArray[i]
 	}
 
-	obs.Illuminance = int(obsArray[9].(float64))
-	obs.UV = int(obsArray[10].(float64))
-	obs.SolarRadiation = int(obsArray[11].(float64))
-	obs.RainAccumulated = obsArray[12].(float64)
-	obs.PrecipitationType = int(obsArray[13].(float64))
-	obs.LightningStrikeAvgDistance = int(obsArray[14].(float64))
-	obs.LightningStrikeCount = int(obsArray[15].(float64))
-	obs.Battery = obsArray[16].(float64)
-	obs.ReportInterval = int(obsArray[17].(float64))
-	obs.LocalDailyRainAccumulation = obsArray[18].(float64)
-	obs.RainAccumulatedFinal = obsArray[19].(float64)
-	obs.LocalDailyRainAccumulationFinal = obsArray[20].(float64)
-	obs.PrecipitationAnalysisType = int(obsArray[21].(float64))
+	rw.TimeEpoch = int(value(0))
+	rw.WindSpeed = value(1)
+	rw.WindDirection = int(value(2))
 
 	return nil
 }
 
-func (rw *RapidWindData) UnmarshalJSON(data []byte) error {
-	var rwArray []interface{}
-	err := json.Unmarshal(data, &rwArray)
+// Time returns the time of the observation.
+func (rw RapidWindData) Time() time.Time {
+	return time.Unix(int64(rw.TimeEpoch), 0)
+}
+
+// MarshalJSON encodes rw in the positional array form used on the wire.
+func (rw RapidWindData) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{rw.TimeEpoch, rw.WindSpeed, rw.WindDirection})
+}
+
+// rapidWindDataNamed has RapidWindData's fields but not its methods, so it
+// encodes as an object keyed by the field tags.
+type rapidWindDataNamed RapidWindData
+
+// MarshalNamedJSON encodes rw as an object keyed by field name, which is
+// easier to read than the wire format. UnmarshalJSON accepts either form.
+func (rw RapidWindData) MarshalNamedJSON() ([]byte, error) {
+	return json.Marshal(rapidWindDataNamed(rw))
+}
+
+func (evt *EvtPrecipData) UnmarshalJSON(data []byte) error {
+	// Accept the named-field form produced by MarshalNamedJSON too.
+	if isJSONObject(data) {
+		return json.Unmarshal(data, (*evtPrecipDataNamed)(evt))
+	}
+
+	var evtArray []int
+	err := json.Unmarshal(data, &evtArray)
+	if err != nil {
+		return err
+	}
+	if len(evtArray) < 1 {
+		return fmt.Errorf("evt_precip event has %d fields, want 1", len(evtArray))
+	}
+
+	evt.TimeEpoch = evtArray[0]
+
+	return nil
+}
+
+// Time returns the time of the event.
+func (evt EvtPrecipData) Time() time.Time {
+	return time.Unix(int64(evt.TimeEpoch), 0)
+}
+
+// MarshalJSON encodes evt in the positional array form used on the wire.
+func (evt EvtPrecipData) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]int{evt.TimeEpoch})
+}
+
+// evtPrecipDataNamed has EvtPrecipData's fields but not its methods, so it
+// encodes as an object keyed by the field tags.
+type evtPrecipDataNamed EvtPrecipData
+
+// MarshalNamedJSON encodes evt as an object keyed by field name.
+// UnmarshalJSON accepts either form.
+func (evt EvtPrecipData) MarshalNamedJSON() ([]byte, error) {
+	return json.Marshal(evtPrecipDataNamed(evt))
+}
+
+func (evt *EvtStrikeData) UnmarshalJSON(data []byte) error {
+	// Accept the named-field form produced by MarshalNamedJSON too.
+	if isJSONObject(data) {
+		return json.Unmarshal(data, (*evtStrikeDataNamed)(evt))
+	}
+
+	var evtArray []int
+	err := json.Unmarshal(data, &evtArray)
 	if err != nil {
 		return err
 	}
+	if len(evtArray) < 3 {
+		return fmt.Errorf("evt_strike event has %d fields, want 3", len(evtArray))
+	}
 
-	rw.TimeEpoch = int(rwArray[0].(float64))
-	rw.WindSpeed = rwArray[1].(float64)
-	rw.WindDirection = int(rwArray[2].(float64))
+	evt.TimeEpoch = evtArray[0]
+	evt.Distance = evtArray[1]
+	evt.Energy = evtArray[2]
 
 	return nil
 }
 
+// Time returns the time of the strike.
+func (evt EvtStrikeData) Time() time.Time {
+	return time.Unix(int64(evt.TimeEpoch), 0)
+}
+
+// MarshalJSON encodes evt in the positional array form used on the wire.
+func (evt EvtStrikeData) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]int{evt.TimeEpoch, evt.Distance, evt.Energy})
+}
+
+// evtStrikeDataNamed has EvtStrikeData's fields but not its methods, so it
+// encodes as an object keyed by the field tags.
+type evtStrikeDataNamed EvtStrikeData
+
+// MarshalNamedJSON encodes evt as an object keyed by field name.
+// UnmarshalJSON accepts either form.
+func (evt EvtStrikeData) MarshalNamedJSON() ([]byte, error) {
+	return json.Marshal(evtStrikeDataNamed(evt))
+}
+
+// epochTime converts a Unix timestamp to a time.Time, mapping 0 (used by the
+// API for "never") to the zero time.
+func epochTime(epoch int) time.Time {
+	if epoch == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(epoch), 0)
+}
+
+// isJSONObject reports whether data holds a JSON object rather than an
+// array.
+func isJSONObject(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) > 0 && data[0] == '{'
+}
+
 func (w *MessageObsSt) GetType() string {
 	return w.Type
 }
@@ -155,6 +539,14 @@ func (w *MessageRapidWind) GetType() string {
 	return w.Type
 }
 
+func (w *MessageEvtPrecip) GetType() string {
+	return w.Type
+}
+
+func (w *MessageEvtStrike) GetType() string {
+	return w.Type
+}
+
 func (w *MessageConnectionOpened) GetType() string {
 	return w.Type
 }
@@ -163,6 +555,10 @@ func (w *MessageAck) GetType() string {
 	return w.Type
 }
 
+func (w *MessageError) GetType() string {
+	return w.Type
+}
+
 func (w *MessageObsSt) GetDeviceID() (int, bool) {
 	return w.DeviceID, true
 }
@@ -171,6 +567,14 @@ func (w *MessageRapidWind) GetDeviceID() (int, bool) {
 	return w.DeviceID, true
 }
 
+func (w *MessageEvtPrecip) GetDeviceID() (int, bool) {
+	return w.DeviceID, true
+}
+
+func (w *MessageEvtStrike) GetDeviceID() (int, bool) {
+	return w.DeviceID, true
+}
+
 func (w *MessageConnectionOpened) GetDeviceID() (int, bool) {
 	return -1, false
 }
@@ -179,36 +583,78 @@ func (w *MessageAck) GetDeviceID() (int, bool) {
 	return -1, false
 }
 
+func (w *MessageError) GetDeviceID() (int, bool) {
+	if w.DeviceID != 0 {
+		return w.DeviceID, true
+	}
+
+	// Fall back to the device named in the ID of the failed request (see
+	// sendListenStart).
+	i := strings.LastIndexByte(w.ID, '_')
+	if i < 0 {
+		return -1, false
+	}
+	id, err := strconv.Atoi(w.ID[i+1:])
+	if err != nil {
+		return -1, false
+	}
+	return id, true
+}
+
+// UnmarshalMessage decodes a message received from the WeatherFlow server,
+// keeping a copy of data in its Meta. Malformed messages produce a
+// *DecodeError, and messages of a type this package doesn't support produce
+// a *ProtocolError.
 func UnmarshalMessage(data []byte) (Message, error) {
+	return UnmarshalMessageFrom(data, OriginUnknown, time.Time{})
+}
+
+// UnmarshalMessageFrom is like UnmarshalMessage, but also records where and
+// when the message was received. Messages from the WebSocket API that the
+// server marks as cached are given OriginCache.
+func UnmarshalMessageFrom(data []byte, origin Origin, receivedAt time.Time) (Message, error) {
 	var rawMessage map[string]interface{}
 	err := json.Unmarshal(data, &rawMessage)
 	if err != nil {
-		return nil, err
+		return nil, &DecodeError{Err: err}
 	}
 
 	messageType, ok := rawMessage["type"].(string)
 	if !ok {
-		return nil, fmt.Errorf("missing 'type' field in message")
+		return nil, &DecodeError{Err: fmt.Errorf("missing 'type' field in message")}
 	}
 
+	var message Message
 	switch messageType {
 	case "obs_st":
-		var message MessageObsSt
-		err := json.Unmarshal(data, &message)
-		return &message, err
+		message = &MessageObsSt{}
 	case "rapid_wind":
-		var message MessageRapidWind
-		err := json.Unmarshal(data, &message)
-		return &message, err
+		message = &MessageRapidWind{}
+	case "evt_precip":
+		message = &MessageEvtPrecip{}
+	case "evt_strike":
+		message = &MessageEvtStrike{}
 	case "connection_opened":
-		var message MessageConnectionOpened
-		err := json.Unmarshal(data, &message)
-		return &message, err
+		message = &MessageConnectionOpened{}
 	case "ack":
-		var message MessageAck
-		err := json.Unmarshal(data, &message)
-		return &message, err
+		message = &MessageAck{}
+	case "error":
+		message = &MessageError{}
 	default:
-		return nil, fmt.Errorf("unsupported message type: %s", messageType)
+		return nil, &ProtocolError{Err: fmt.Errorf("unsupported message type: %s", messageType)}
 	}
+
+	if err := json.Unmarshal(data, message); err != nil {
+		return message, &DecodeError{Type: messageType, Err: err}
+	}
+
+	if m, ok := message.(*MessageObsSt); ok && m.Source == "cache" && origin == OriginWebSocket {
+		origin = OriginCache
+	}
+
+	meta := message.GetMeta()
+	meta.Raw = append([]byte(nil), data...)
+	meta.ReceivedAt = receivedAt
+	meta.Origin = origin
+	return message, nil
 }
@@ -1,12 +1,20 @@
 package weatherflow_test
 
 import (
+	"encoding/json"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/tris/weatherflow"
 )
 
+func float64Ptr(v float64) *float64 {
+	return &v
+}
+
 func TestUnmarshalWeatherMessage(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -84,6 +92,76 @@ func TestUnmarshalWeatherMessage(t *testing.T) {
 			},
 			wantError: false,
 		},
+		{
+			name:  "evt_precip message",
+			input: `{"device_id":121037,"type":"evt_precip","source":"enhanced_mqtt","evt":[1681701864]}`,
+			want: &weatherflow.MessageEvtPrecip{
+				Type:     "evt_precip",
+				DeviceID: 121037,
+				Source:   "enhanced_mqtt",
+				Evt:      weatherflow.EvtPrecipData{TimeEpoch: 1681701864},
+			},
+			wantError: false,
+		},
+		{
+			name:  "evt_strike message",
+			input: `{"device_id":121037,"type":"evt_strike","evt":[1681701864,27,3848]}`,
+			want: &weatherflow.MessageEvtStrike{
+				Type:     "evt_strike",
+				DeviceID: 121037,
+				Evt:      weatherflow.EvtStrikeData{TimeEpoch: 1681701864, Distance: 27, Energy: 3848},
+			},
+			wantError: false,
+		},
+		{
+			name:  "obs_st summary with derived and unknown fields",
+			input: `{"status":{"status_code":0,"status_message":"SUCCESS"},"device_id":121037,"type":"obs_st","source":"mqtt","summary":{"pressure_trend":"falling","strike_count_1h":0,"strike_count_3h":0,"precip_total_1h":0.0,"strike_last_dist":38,"strike_last_epoch":1679435903,"precip_accum_local_yesterday":0.0,"precip_accum_local_yesterday_final":0.0,"precip_analysis_type_yesterday":0,"feels_like":12.1,"heat_index":12.1,"wind_chill":11.4,"dew_point":4.3,"wet_bulb_temperature":8.2,"wet_bulb_globe_temperature":10.9,"delta_t":3.9,"air_density":1.21,"raining_minutes":[0,0,0,0,0,0,0,0,0,0,0,0],"precip_minutes_local_day":0,"precip_minutes_local_yesterday":0,"precip_minutes_local_yesterday_final":0,"pulse_adj_ob_time":1681701778,"pulse_adj_ob_wind_avg":4.1,"pulse_adj_ob_temp":12.1,"future_field":{"a":1}},"obs":[]}`,
+			want: &weatherflow.MessageObsSt{
+				Status: weatherflow.ObsStStatus{
+					StatusCode:    0,
+					StatusMessage: "SUCCESS",
+				},
+				DeviceID: 121037,
+				Type:     "obs_st",
+				Source:   "mqtt",
+				Summary: weatherflow.ObsStSummary{
+					PressureTrend:                    "falling",
+					StrikeLastDist:                   38,
+					StrikeLastEpoch:                  1679435903,
+					FeelsLike:                        float64Ptr(12.1),
+					HeatIndex:                        float64Ptr(12.1),
+					WindChill:                        float64Ptr(11.4),
+					DewPoint:                         float64Ptr(4.3),
+					WetBulbTemperature:               float64Ptr(8.2),
+					WetBulbGlobeTemperature:          float64Ptr(10.9),
+					DeltaT:                           float64Ptr(3.9),
+					AirDensity:                       float64Ptr(1.21),
+					RainingMinutes:                   []int{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+					PrecipMinutesLocalYesterdayFinal: 0,
+					PulseAdjObTime:                   1681701778,
+					PulseAdjObWindAvg:                float64Ptr(4.1),
+					PulseAdjObTemp:                   float64Ptr(12.1),
+					Extras: map[string]json.RawMessage{
+						"future_field": json.RawMessage(`{"a":1}`),
+					},
+				},
+				Obs: []weatherflow.ObsStData{},
+			},
+			wantError: false,
+		},
+		{
+			name:  "error message",
+			input: `{"type":"error","id":"listen_start_121037","status":{"status_code":401,"status_message":"NOT AUTHORIZED"}}`,
+			want: &weatherflow.MessageError{
+				ID:   "listen_start_121037",
+				Type: "error",
+				Status: weatherflow.ObsStStatus{
+					StatusCode:    401,
+					StatusMessage: "NOT AUTHORIZED",
+				},
+			},
+			wantError: false,
+		},
 	}
 
 	for _, test := range tests {
@@ -97,10 +175,243 @@ func TestUnmarshalWeatherMessage(t *testing.T) {
 				if err != nil {
 					t.Errorf("Unexpected error: %v", err)
 				}
-				if diff := cmp.Diff(got, test.want); diff != "" {
+				if diff := cmp.Diff(got, test.want, cmpopts.IgnoreTypes(weatherflow.Meta{})); diff != "" {
 					t.Errorf("UnmarshalMessage() mismatch (-got +want):\n%s", diff)
 				}
 			}
 		})
 	}
 }
+
+func TestUnmarshalMessageErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantType string
+		want     interface{}
+	}{
+		{
+			name:  "invalid JSON",
+			input: `{"type":`,
+			want:  &weatherflow.DecodeError{},
+		},
+		{
+			name:  "missing type",
+			input: `{"device_id":121037}`,
+			want:  &weatherflow.DecodeError{},
+		},
+		{
+			name:     "short rapid_wind observation",
+			input:    `{"device_id":121037,"type":"rapid_wind","ob":[1681701864,4.29]}`,
+			wantType: "rapid_wind",
+			want:     &weatherflow.DecodeError{},
+		},
+		{
+			name:  "unsupported type",
+			input: `{"type":"obs_unknown"}`,
+			want:  &weatherflow.ProtocolError{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := weatherflow.UnmarshalMessage([]byte(test.input))
+			if err == nil {
+				t.Fatal("Expected an error but got none")
+			}
+
+			switch want := test.want.(type) {
+			case *weatherflow.DecodeError:
+				if !errors.As(err, &want) {
+					t.Fatalf("Got %T (%v), want *DecodeError", err, err)
+				}
+				if want.Type != test.wantType {
+					t.Errorf("DecodeError.Type = %q, want %q", want.Type, test.wantType)
+				}
+			case *weatherflow.ProtocolError:
+				if !errors.As(err, &want) {
+					t.Fatalf("Got %T (%v), want *ProtocolError", err, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMessageErrorDeviceID(t *testing.T) {
+	tests := []struct {
+		msg    weatherflow.MessageError
+		wantID int
+		wantOK bool
+	}{
+		{weatherflow.MessageError{DeviceID: 121037}, 121037, true},
+		{weatherflow.MessageError{ID: "listen_rapid_start_121037"}, 121037, true},
+		{weatherflow.MessageError{ID: "something_else"}, -1, false},
+		{weatherflow.MessageError{}, -1, false},
+	}
+
+	for _, test := range tests {
+		id, ok := test.msg.GetDeviceID()
+		if id != test.wantID || ok != test.wantOK {
+			t.Errorf("%+v.GetDeviceID() = %d, %v, want %d, %v", test.msg, id, ok, test.wantID, test.wantOK)
+		}
+	}
+}
+
+func TestUnmarshalMessageMeta(t *testing.T) {
+	receivedAt := time.Date(2023, 4, 17, 3, 24, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		input      string
+		origin     weatherflow.Origin
+		wantOrigin weatherflow.Origin
+	}{
+		{
+			name:       "live rapid_wind",
+			input:      `{"device_id":121037,"type":"rapid_wind","ob":[1681701864,4.29,298]}`,
+			origin:     weatherflow.OriginWebSocket,
+			wantOrigin: weatherflow.OriginWebSocket,
+		},
+		{
+			name:       "cached obs_st",
+			input:      `{"status":{"status_code":0,"status_message":"SUCCESS"},"device_id":121037,"type":"obs_st","source":"cache","summary":{},"obs":[]}`,
+			origin:     weatherflow.OriginWebSocket,
+			wantOrigin: weatherflow.OriginCache,
+		},
+		{
+			name:       "replayed obs_st",
+			input:      `{"status":{"status_code":0,"status_message":"SUCCESS"},"device_id":121037,"type":"obs_st","source":"cache","summary":{},"obs":[]}`,
+			origin:     weatherflow.OriginReplay,
+			wantOrigin: weatherflow.OriginReplay,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := []byte(test.input)
+			got, err := weatherflow.UnmarshalMessageFrom(input, test.origin, receivedAt)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			// The raw bytes must survive the caller reusing its buffer.
+			copy(input, "XXXX")
+
+			want := &weatherflow.Meta{
+				Raw:        []byte(test.input),
+				ReceivedAt: receivedAt,
+				Origin:     test.wantOrigin,
+			}
+			if diff := cmp.Diff(got.GetMeta(), want); diff != "" {
+				t.Errorf("GetMeta() mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{
+			name:  "obs_st message",
+			input: `{"status":{"status_code":0,"status_message":"SUCCESS"},"device_id":121037,"type":"obs_st","source":"cache","summary":{"pressure_trend":"steady","strike_count_1h":0,"strike_count_3h":0,"precip_total_1h":0.0,"strike_last_dist":38,"strike_last_epoch":1679435903,"precip_accum_local_yesterday":0.0,"precip_accum_local_yesterday_final":0.0,"precip_analysis_type_yesterday":0,"feels_like":12.1,"raining_minutes":[0,0,0,0,0,0,0,0,0,0,0,0],"precip_minutes_local_day":0,"precip_minutes_local_yesterday":0,"precip_minutes_local_yesterday_final":0,"future_field":{"a":1}},"obs":[[1681767864,4.19,4.24,4.27,285,20,722.7,null,null,109435,6.19,912,0,0,0,0,2.46,1,0,0,0,0]]}`,
+		},
+		{
+			// A failed wind sensor and light sensor.
+			name:  "obs_st message with nulls",
+			input: `{"status":{"status_code":0,"status_message":"SUCCESS"},"device_id":121037,"type":"obs_st","source":"cache","summary":{"pressure_trend":"steady","strike_count_1h":0,"strike_count_3h":0,"precip_total_1h":0.0,"strike_last_dist":38,"strike_last_epoch":1679435903,"precip_accum_local_yesterday":0.0,"precip_accum_local_yesterday_final":0.0,"precip_analysis_type_yesterday":0,"raining_minutes":[0,0,0,0,0,0,0,0,0,0,0,0],"precip_minutes_local_day":0,"precip_minutes_local_yesterday":0,"precip_minutes_local_yesterday_final":0},"obs":[[1681767864,null,null,null,null,3,722.7,12.5,80,null,null,null,0,0,0,0,2.46,1,0,0,0,0]]}`,
+		},
+		{
+			name:  "rapid_wind message",
+			input: `{"device_id":121037,"serial_number":"ST-00026524","type":"rapid_wind","hub_sn":"HB-00039816","ob":[1681701864,4.29,298]}`,
+		},
+		{
+			name:  "evt_precip message",
+			input: `{"device_id":121037,"serial_number":"ST-00026524","type":"evt_precip","hub_sn":"HB-00039816","source":"enhanced_mqtt","evt":[1681701864]}`,
+		},
+		{
+			name:  "evt_strike message",
+			input: `{"device_id":121037,"serial_number":"ST-00026524","type":"evt_strike","hub_sn":"HB-00039816","source":"enhanced_mqtt","evt":[1681701864,27,3848]}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			msg, err := weatherflow.UnmarshalMessage([]byte(test.input))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			data, err := json.Marshal(msg)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+
+			// The encoding matches the wire format...
+			var got, want interface{}
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(test.input), &want); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(got, want); diff != "" {
+				t.Errorf("Marshal mismatch (-got +want):\n%s", diff)
+			}
+
+			// ...and decodes back to the same message.
+			again, err := weatherflow.UnmarshalMessage(data)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(again, msg, cmpopts.IgnoreTypes(weatherflow.Meta{})); diff != "" {
+				t.Errorf("Round trip mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMarshalNamedJSON(t *testing.T) {
+	pressure := 722.7
+	obs := weatherflow.ObsStData{
+		TimeEpoch:       1681767864,
+		WindAvg:         4.24,
+		StationPressure: &pressure,
+		UV:              6.19,
+	}
+
+	data, err := obs.MarshalNamedJSON()
+	if err != nil {
+		t.Fatalf("MarshalNamedJSON failed: %v", err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("MarshalNamedJSON produced invalid JSON: %v", err)
+	}
+	if fields["station_pressure"] != 722.7 || fields["air_temperature"] != nil {
+		t.Errorf("Unexpected named fields: %s", data)
+	}
+
+	var got weatherflow.ObsStData
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal of named form failed: %v", err)
+	}
+	if diff := cmp.Diff(got, obs); diff != "" {
+		t.Errorf("Named round trip mismatch (-got +want):\n%s", diff)
+	}
+
+	rw := weatherflow.RapidWindData{TimeEpoch: 1681701864, WindSpeed: 4.29, WindDirection: 298}
+	data, err = rw.MarshalNamedJSON()
+	if err != nil {
+		t.Fatalf("MarshalNamedJSON failed: %v", err)
+	}
+	var gotRW weatherflow.RapidWindData
+	if err := json.Unmarshal(data, &gotRW); err != nil {
+		t.Fatalf("Unmarshal of named form failed: %v", err)
+	}
+	if gotRW != rw {
+		t.Errorf("Named round trip got %+v, want %+v", gotRW, rw)
+	}
+}
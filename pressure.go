@@ -0,0 +1,240 @@
+package weatherflow
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Constants of the International Standard Atmosphere.
+const (
+	standardPressure   = 1013.25  // hPa at sea level
+	standardTemp       = 288.15   // K at sea level
+	standardLapseRate  = 0.0065   // K/m
+	standardGravity    = 9.80665  // m/s²
+	altimeterExponent  = 0.190284 // Rd·L/g, as used by NOAA
+	altimeterIndexCorr = 0.3      // hPa, NOAA's instrument correction
+)
+
+// SeaLevelPressure returns the station pressure reduced to sea level, in
+// hPa, given the barometer's elevation in metres (see
+// DeviceInfo.BarometerElevation). It uses the hypsometric equation with the
+// mean virtual temperature of a standard-lapse-rate column between the
+// station and sea level, as the WMO recommends for stations at modest
+// elevations. It needs StationPressure and AirTemperature; humidity is
+// used if available.
+func (obs ObsStData) SeaLevelPressure(elevation float64) (float64, bool) {
+	if obs.StationPressure == nil || obs.AirTemperature == nil {
+		return 0, false
+	}
+
+	p := *obs.StationPressure
+	t := *obs.AirTemperature + zeroCelsius + standardLapseRate*elevation/2
+	if e, ok := obs.VaporPressure(); ok {
+		// Virtual temperature accounts for moist air being less dense.
+		t /= 1 - e/p*(1-gasConstantDryAir/gasConstantVapour)
+	}
+
+	return p * math.Exp(standardGravity*elevation/(gasConstantDryAir*t)), true
+}
+
+// AltimeterSetting returns the altimeter setting (QNH), in hPa, given the
+// barometer's elevation in metres, using the NOAA/NWS formula. Unlike
+// SeaLevelPressure it assumes a standard atmosphere and so doesn't depend on
+// the air temperature.
+func (obs ObsStData) AltimeterSetting(elevation float64) (float64, bool) {
+	if obs.StationPressure == nil {
+		return 0, false
+	}
+
+	p := *obs.StationPressure - altimeterIndexCorr
+	k := math.Pow(standardPressure, altimeterExponent) * standardLapseRate / standardTemp
+	return p * math.Pow(1+k*elevation/math.Pow(p, altimeterExponent), 1/altimeterExponent), true
+}
+
+const (
+	tendencyPeriod    = 3 * time.Hour
+	tendencyTolerance = 15 * time.Minute // how far a sample may be from the time it stands in for
+	tendencySteady    = 0.1              // hPa; smaller changes count as steady
+	trendThreshold    = 1.0              // hPa over the period; as WeatherFlow's pressure_trend
+)
+
+// PressureTendency describes how a device's pressure has changed over the
+// last three hours.
+type PressureTendency struct {
+	Change float64       // hPa since three hours ago
+	Code   int           // WMO code table 0200 (0–8)
+	Trend  PressureTrend // coarse trend, as in ObsStSummary.PressureTrend
+}
+
+// PressureTracker keeps a few hours of station pressure readings for each
+// device so that the pressure tendency can be computed locally. It is safe
+// for concurrent use.
+type PressureTracker struct {
+	history map[int][]pressureSample
+	mu      sync.Mutex
+}
+
+type pressureSample struct {
+	time     time.Time
+	pressure float64
+}
+
+func NewPressureTracker() *PressureTracker {
+	return &PressureTracker{
+		history: make(map[int][]pressureSample),
+	}
+}
+
+// Handle records the pressure readings in an obs_st message. Other messages
+// are ignored.
+func (t *PressureTracker) Handle(msg Message) {
+	if m, ok := msg.(*MessageObsSt); ok {
+		for _, obs := range m.Obs {
+			t.Add(m.DeviceID, obs)
+		}
+	}
+}
+
+// Add records the pressure reading in obs for a device.
+func (t *PressureTracker) Add(deviceID int, obs ObsStData) {
+	if obs.StationPressure == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := pressureSample{time: obs.Time(), pressure: *obs.StationPressure}
+	h := t.history[deviceID]
+
+	// Readings normally arrive in order, but replays from the cache may not.
+	i := sort.Search(len(h), func(i int) bool { return !h[i].time.Before(s.time) })
+	if i < len(h) && h[i].time.Equal(s.time) {
+		return
+	}
+	h = append(h, pressureSample{})
+	copy(h[i+1:], h[i:])
+	h[i] = s
+
+	// Forget readings too old to be needed.
+	cutoff := h[len(h)-1].time.Add(-tendencyPeriod - tendencyTolerance)
+	drop := 0
+	for drop < len(h) && h[drop].time.Before(cutoff) {
+		drop++
+	}
+	t.history[deviceID] = h[drop:]
+}
+
+// Tendency returns the pressure tendency over the three hours up to the
+// device's latest reading. ok is false if there isn't enough history.
+func (t *PressureTracker) Tendency(deviceID int) (tendency PressureTendency, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h := t.history[deviceID]
+	if len(h) == 0 {
+		return PressureTendency{}, false
+	}
+
+	now := h[len(h)-1]
+	start, ok := nearestSample(h, now.time.Add(-tendencyPeriod))
+	if !ok {
+		return PressureTendency{}, false
+	}
+	mid, ok := nearestSample(h, now.time.Add(-tendencyPeriod/2))
+	if !ok {
+		return PressureTendency{}, false
+	}
+
+	change := now.pressure - start.pressure
+	return PressureTendency{
+		Change: change,
+		Code:   tendencyCode(mid.pressure-start.pressure, now.pressure-mid.pressure),
+		Trend:  pressureTrend(change),
+	}, true
+}
+
+// nearestSample returns the sample closest to at, if one is within
+// tendencyTolerance. h must be sorted.
+func nearestSample(h []pressureSample, at time.Time) (pressureSample, bool) {
+	i := sort.Search(len(h), func(i int) bool { return !h[i].time.Before(at) })
+
+	var best pressureSample
+	bestDiff := time.Duration(math.MaxInt64)
+	for _, j := range []int{i - 1, i} {
+		if j < 0 || j >= len(h) {
+			continue
+		}
+		diff := h[j].time.Sub(at)
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff < bestDiff {
+			best, bestDiff = h[j], diff
+		}
+	}
+	return best, bestDiff <= tendencyTolerance
+}
+
+// tendencyCode returns the WMO code table 0200 characteristic of pressure
+// tendency, given the changes over the first and second halves of the
+// period.
+func tendencyCode(first, second float64) int {
+	rising := func(v float64) bool { return v >= tendencySteady }
+	falling := func(v float64) bool { return v <= -tendencySteady }
+	total := first + second
+
+	switch {
+	case rising(total):
+		switch {
+		case rising(first) && falling(second):
+			return 0 // increasing, then decreasing
+		case rising(first) && !rising(second):
+			return 1 // increasing, then steady
+		case rising(first) && second < first-tendencySteady:
+			return 1 // increasing, then increasing more slowly
+		case !rising(first) || second > first+tendencySteady:
+			return 3 // decreasing or steady, then increasing; or increasing more rapidly
+		default:
+			return 2 // increasing steadily
+		}
+
+	case falling(total):
+		switch {
+		case falling(first) && rising(second):
+			return 5 // decreasing, then increasing
+		case falling(first) && !falling(second):
+			return 6 // decreasing, then steady
+		case falling(first) && second > first+tendencySteady:
+			return 6 // decreasing, then decreasing more slowly
+		case !falling(first) || second < first-tendencySteady:
+			return 8 // steady or increasing, then decreasing; or decreasing more rapidly
+		default:
+			return 7 // decreasing steadily
+		}
+
+	default:
+		switch {
+		case rising(first) && falling(second):
+			return 0 // increasing, then decreasing
+		case falling(first) && rising(second):
+			return 5 // decreasing, then increasing
+		default:
+			return 4 // steady
+		}
+	}
+}
+
+// pressureTrend classifies a three-hour pressure change.
+func pressureTrend(change float64) PressureTrend {
+	switch {
+	case change >= trendThreshold:
+		return PressureRising
+	case change <= -trendThreshold:
+		return PressureFalling
+	default:
+		return PressureSteady
+	}
+}
@@ -0,0 +1,160 @@
+package weatherflow_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tris/weatherflow"
+)
+
+func TestPressureReduction(t *testing.T) {
+	tests := []struct {
+		name          string
+		obs           weatherflow.ObsStData
+		elevation     float64
+		wantSeaLevel  float64
+		wantAltimeter float64
+		wantOK        bool
+	}{
+		{
+			name:          "low station",
+			obs:           weatherflow.ObsStData{StationPressure: float64Ptr(1000), AirTemperature: float64Ptr(15)},
+			elevation:     100,
+			wantSeaLevel:  1011.9,
+			wantAltimeter: 1011.6,
+			wantOK:        true,
+		},
+		{
+			name:          "high station",
+			obs:           weatherflow.ObsStData{StationPressure: float64Ptr(900), AirTemperature: float64Ptr(10)},
+			elevation:     1000,
+			wantSeaLevel:  1014.0,
+			wantAltimeter: 1014.3,
+			wantOK:        true,
+		},
+		{
+			name:          "high station, humid",
+			obs:           weatherflow.ObsStData{StationPressure: float64Ptr(900), AirTemperature: float64Ptr(10), RelativeHumidity: float64Ptr(50)},
+			elevation:     1000,
+			wantSeaLevel:  1013.7,
+			wantAltimeter: 1014.3,
+			wantOK:        true,
+		},
+		{
+			name:      "missing pressure",
+			obs:       weatherflow.ObsStData{AirTemperature: float64Ptr(10)},
+			elevation: 1000,
+		},
+	}
+
+	for _, test := range tests {
+		slp, ok := test.obs.SeaLevelPressure(test.elevation)
+		if ok != test.wantOK || (ok && math.Abs(slp-test.wantSeaLevel) > 0.05) {
+			t.Errorf("%s: SeaLevelPressure = %.2f, %v; want %.2f, %v", test.name, slp, ok, test.wantSeaLevel, test.wantOK)
+		}
+		alt, ok := test.obs.AltimeterSetting(test.elevation)
+		if ok != test.wantOK || (ok && math.Abs(alt-test.wantAltimeter) > 0.05) {
+			t.Errorf("%s: AltimeterSetting = %.2f, %v; want %.2f, %v", test.name, alt, ok, test.wantAltimeter, test.wantOK)
+		}
+	}
+
+	// Sea level pressure needs the temperature; the altimeter setting doesn't.
+	obs := weatherflow.ObsStData{StationPressure: float64Ptr(900)}
+	if _, ok := obs.SeaLevelPressure(1000); ok {
+		t.Error("SeaLevelPressure without temperature: expected ok = false")
+	}
+	if _, ok := obs.AltimeterSetting(1000); !ok {
+		t.Error("AltimeterSetting without temperature: expected ok = true")
+	}
+}
+
+func TestPressureTendency(t *testing.T) {
+	const start = 1681700000
+
+	tests := []struct {
+		name       string
+		first      float64 // change over the first 90 minutes
+		second     float64 // change over the last 90 minutes
+		wantCode   int
+		wantChange float64
+		wantTrend  weatherflow.PressureTrend
+	}{
+		{name: "steady", first: 0, second: 0, wantCode: 4, wantTrend: weatherflow.PressureSteady},
+		{name: "rising steadily", first: 1, second: 1, wantCode: 2, wantChange: 2, wantTrend: weatherflow.PressureRising},
+		{name: "rising then falling", first: 1.5, second: -0.5, wantCode: 0, wantChange: 1, wantTrend: weatherflow.PressureRising},
+		{name: "rising then steady", first: 0.8, second: 0, wantCode: 1, wantChange: 0.8, wantTrend: weatherflow.PressureSteady},
+		{name: "rising faster", first: 0.2, second: 1.5, wantCode: 3, wantChange: 1.7, wantTrend: weatherflow.PressureRising},
+		{name: "up and back down", first: 1, second: -1, wantCode: 0, wantTrend: weatherflow.PressureSteady},
+		{name: "down and back up", first: -1, second: 1, wantCode: 5, wantTrend: weatherflow.PressureSteady},
+		{name: "falling steadily", first: -1, second: -1, wantCode: 7, wantChange: -2, wantTrend: weatherflow.PressureFalling},
+		{name: "falling then steady", first: -2, second: 0, wantCode: 6, wantChange: -2, wantTrend: weatherflow.PressureFalling},
+		{name: "falling then rising", first: -2, second: 0.5, wantCode: 5, wantChange: -1.5, wantTrend: weatherflow.PressureFalling},
+		{name: "falling faster", first: -0.2, second: -1.5, wantCode: 8, wantChange: -1.7, wantTrend: weatherflow.PressureFalling},
+	}
+
+	for _, test := range tests {
+		tracker := weatherflow.NewPressureTracker()
+
+		// One reading a minute for three hours, changing linearly over each
+		// half.
+		for i := 0; i <= 180; i++ {
+			p := 1000.0
+			if i <= 90 {
+				p += test.first * float64(i) / 90
+			} else {
+				p += test.first + test.second*float64(i-90)/90
+			}
+			tracker.Add(1, weatherflow.ObsStData{TimeEpoch: start + i*60, StationPressure: float64Ptr(p)})
+		}
+
+		got, ok := tracker.Tendency(1)
+		if !ok {
+			t.Errorf("%s: Tendency: expected ok = true", test.name)
+			continue
+		}
+		if got.Code != test.wantCode || math.Abs(got.Change-test.wantChange) > 1e-9 || got.Trend != test.wantTrend {
+			t.Errorf("%s: got %+v, want code %d, change %v, trend %v", test.name, got, test.wantCode, test.wantChange, test.wantTrend)
+		}
+	}
+}
+
+func TestPressureTrackerHistory(t *testing.T) {
+	const start = 1681700000
+	tracker := weatherflow.NewPressureTracker()
+
+	if _, ok := tracker.Tendency(1); ok {
+		t.Error("Tendency with no readings: expected ok = false")
+	}
+
+	// Two hours isn't enough.
+	for i := 0; i <= 120; i += 5 {
+		tracker.Add(1, weatherflow.ObsStData{TimeEpoch: start + i*60, StationPressure: float64Ptr(1000)})
+	}
+	if _, ok := tracker.Tendency(1); ok {
+		t.Error("Tendency with two hours of readings: expected ok = false")
+	}
+
+	// Readings without pressure are ignored, readings from the cache may
+	// arrive out of order, and other devices are tracked separately.
+	tracker.Add(1, weatherflow.ObsStData{TimeEpoch: start + 200*60})
+	tracker.Handle(&weatherflow.MessageObsSt{
+		DeviceID: 1,
+		Obs: []weatherflow.ObsStData{
+			{TimeEpoch: start + 180*60, StationPressure: float64Ptr(997)},
+			{TimeEpoch: start - 60*60, StationPressure: float64Ptr(990)},
+		},
+	})
+	tracker.Add(2, weatherflow.ObsStData{TimeEpoch: start, StationPressure: float64Ptr(1020)})
+
+	got, ok := tracker.Tendency(1)
+	if !ok {
+		t.Fatal("Tendency: expected ok = true")
+	}
+	if got.Change != -3 || got.Code != 8 || got.Trend != weatherflow.PressureFalling {
+		t.Errorf("got %+v, want change -3, code 8, falling", got)
+	}
+
+	if _, ok := tracker.Tendency(2); ok {
+		t.Error("Tendency for device 2: expected ok = false")
+	}
+}
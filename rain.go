@@ -0,0 +1,248 @@
+package weatherflow
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const defaultDryPeriod = 30 * time.Minute
+
+// RainIntensity classifies a rain rate, using the American Meteorological
+// Society's thresholds.
+type RainIntensity int
+
+const (
+	RainNone     RainIntensity = iota
+	RainLight                  // below 2.5 mm/h
+	RainModerate               // 2.5 to 7.6 mm/h
+	RainHeavy                  // 7.6 to 50 mm/h
+	RainViolent                // 50 mm/h or more
+)
+
+func (i RainIntensity) String() string {
+	switch i {
+	case RainNone:
+		return "none"
+	case RainLight:
+		return "light"
+	case RainModerate:
+		return "moderate"
+	case RainHeavy:
+		return "heavy"
+	case RainViolent:
+		return "violent"
+	default:
+		return fmt.Sprintf("RainIntensity(%d)", int(i))
+	}
+}
+
+// ClassifyRainRate returns the intensity of rain falling at rate mm/h.
+func ClassifyRainRate(rate float64) RainIntensity {
+	switch {
+	case rate <= 0:
+		return RainNone
+	case rate < 2.5:
+		return RainLight
+	case rate < 7.6:
+		return RainModerate
+	case rate < 50:
+		return RainHeavy
+	default:
+		return RainViolent
+	}
+}
+
+// RainEventType distinguishes the start and end of a rain event.
+type RainEventType int
+
+const (
+	RainStart RainEventType = iota
+	RainEnd
+)
+
+func (t RainEventType) String() string {
+	switch t {
+	case RainStart:
+		return "start"
+	case RainEnd:
+		return "end"
+	default:
+		return fmt.Sprintf("RainEventType(%d)", int(t))
+	}
+}
+
+// RainEvent reports the start or end of a period of rain at a device. For
+// RainStart, only DeviceID and Start are set.
+type RainEvent struct {
+	Type      RainEventType
+	DeviceID  int
+	Start     time.Time
+	End       time.Time // end of the last interval with rain
+	Duration  time.Duration
+	Total     float64       // mm
+	PeakRate  float64       // mm/h, over a single observation interval
+	Intensity RainIntensity // of PeakRate
+}
+
+// RainConfig configures a RainTracker.
+type RainConfig struct {
+	// DryPeriod is how long it must stay dry for a rain event to end. The
+	// default is 30 minutes.
+	DryPeriod time.Duration
+}
+
+// RainTracker turns each device's observations into rain events, calling
+// its callback when rain starts and ends. Rain is detected from
+// RainAccumulated in obs_st messages, and from evt_precip messages, which
+// usually arrive sooner. It is safe for concurrent use.
+type RainTracker struct {
+	dryPeriod time.Duration
+	onEvent   func(RainEvent)
+	current   map[int]*RainEvent
+	lastWet   map[int]time.Time
+	mu        sync.Mutex
+}
+
+// NewRainTracker creates a RainTracker that passes rain events to onEvent.
+// onEvent is called synchronously from Handle, AddObsSt, AddPrecipEvent or
+// Expire.
+func NewRainTracker(cfg RainConfig, onEvent func(RainEvent)) *RainTracker {
+	if cfg.DryPeriod <= 0 {
+		cfg.DryPeriod = defaultDryPeriod
+	}
+	return &RainTracker{
+		dryPeriod: cfg.DryPeriod,
+		onEvent:   onEvent,
+		current:   make(map[int]*RainEvent),
+		lastWet:   make(map[int]time.Time),
+	}
+}
+
+// Handle records an obs_st or evt_precip message. Other messages are
+// ignored.
+func (r *RainTracker) Handle(msg Message) {
+	switch m := msg.(type) {
+	case *MessageObsSt:
+		for _, obs := range m.Obs {
+			r.AddObsSt(m.DeviceID, obs)
+		}
+	case *MessageEvtPrecip:
+		r.AddPrecipEvent(m.DeviceID, m.Evt.Time())
+	}
+}
+
+// AddObsSt records a device's observation. Rain during the observation's
+// report interval starts an event or extends the current one; a dry
+// observation ends the current event once the dry period has passed.
+func (r *RainTracker) AddObsSt(deviceID int, obs ObsStData) {
+	interval := time.Duration(obs.ReportInterval) * time.Minute
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	end := obs.Time()
+
+	r.mu.Lock()
+	events := r.expire(deviceID, end)
+	if obs.RainAccumulated > 0 {
+		start := end.Add(-interval)
+		events = append(events, r.wet(deviceID, start, end)...)
+
+		e := r.current[deviceID]
+		e.Total += obs.RainAccumulated
+		if rate := obs.RainAccumulated / interval.Hours(); rate > e.PeakRate {
+			e.PeakRate = rate
+			e.Intensity = ClassifyRainRate(rate)
+		}
+	}
+	r.mu.Unlock()
+
+	r.emit(events)
+}
+
+// AddPrecipEvent records an evt_precip message, starting an event if one
+// isn't already in progress.
+func (r *RainTracker) AddPrecipEvent(deviceID int, at time.Time) {
+	r.mu.Lock()
+	events := r.expire(deviceID, at)
+	events = append(events, r.wet(deviceID, at, at)...)
+	r.mu.Unlock()
+
+	r.emit(events)
+}
+
+// Expire ends any events that have been dry for the dry period as of now,
+// for devices that have stopped reporting.
+func (r *RainTracker) Expire(now time.Time) {
+	r.mu.Lock()
+	var events []RainEvent
+	for id := range r.current {
+		events = append(events, r.expire(id, now)...)
+	}
+	r.mu.Unlock()
+
+	r.emit(events)
+}
+
+// Current returns the event in progress at a device, if there is one.
+func (r *RainTracker) Current(deviceID int) (RainEvent, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.current[deviceID]
+	if !ok {
+		return RainEvent{}, false
+	}
+	return *e, true
+}
+
+// wet notes rain at a device from start to end, starting an event if
+// necessary. r.mu must be held.
+func (r *RainTracker) wet(deviceID int, start, end time.Time) []RainEvent {
+	var events []RainEvent
+
+	e, ok := r.current[deviceID]
+	if !ok {
+		e = &RainEvent{DeviceID: deviceID, Start: start}
+		r.current[deviceID] = e
+		events = append(events, RainEvent{Type: RainStart, DeviceID: deviceID, Start: start})
+	}
+	if start.Before(e.Start) {
+		// evt_precip can be followed by an observation covering the minute
+		// before it.
+		e.Start = start
+	}
+	if end.After(e.End) {
+		e.End = end
+		e.Duration = e.End.Sub(e.Start)
+	}
+	if end.After(r.lastWet[deviceID]) {
+		r.lastWet[deviceID] = end
+	}
+	return events
+}
+
+// expire ends the device's event if it has been dry for the dry period as of
+// now. r.mu must be held.
+func (r *RainTracker) expire(deviceID int, now time.Time) []RainEvent {
+	e, ok := r.current[deviceID]
+	if !ok || now.Sub(r.lastWet[deviceID]) < r.dryPeriod {
+		return nil
+	}
+
+	delete(r.current, deviceID)
+	delete(r.lastWet, deviceID)
+
+	end := *e
+	end.Type = RainEnd
+	return []RainEvent{end}
+}
+
+func (r *RainTracker) emit(events []RainEvent) {
+	if r.onEvent == nil {
+		return
+	}
+	for _, e := range events {
+		r.onEvent(e)
+	}
+}
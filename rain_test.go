@@ -0,0 +1,115 @@
+package weatherflow_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tris/weatherflow"
+)
+
+func TestClassifyRainRate(t *testing.T) {
+	tests := []struct {
+		rate float64
+		want weatherflow.RainIntensity
+	}{
+		{0, weatherflow.RainNone},
+		{0.2, weatherflow.RainLight},
+		{2.5, weatherflow.RainModerate},
+		{7.59, weatherflow.RainModerate},
+		{7.6, weatherflow.RainHeavy},
+		{49.9, weatherflow.RainHeavy},
+		{50, weatherflow.RainViolent},
+	}
+
+	for _, test := range tests {
+		if got := weatherflow.ClassifyRainRate(test.rate); got != test.want {
+			t.Errorf("ClassifyRainRate(%v) = %v, want %v", test.rate, got, test.want)
+		}
+	}
+}
+
+func TestRainTracker(t *testing.T) {
+	const start = 1681700000
+	at := func(minute int) time.Time { return time.Unix(start+int64(minute)*60, 0) }
+
+	var events []weatherflow.RainEvent
+	tracker := weatherflow.NewRainTracker(weatherflow.RainConfig{DryPeriod: 10 * time.Minute}, func(e weatherflow.RainEvent) {
+		events = append(events, e)
+	})
+
+	obs := func(minute int, rain float64) {
+		tracker.Handle(&weatherflow.MessageObsSt{
+			DeviceID: 1,
+			Obs:      []weatherflow.ObsStData{{TimeEpoch: start + minute*60, ReportInterval: 1, RainAccumulated: rain}},
+		})
+	}
+
+	obs(0, 0)
+	obs(1, 0)
+	// The rain sensor notices rain part way through minute 2.
+	tracker.Handle(&weatherflow.MessageEvtPrecip{DeviceID: 1, Evt: weatherflow.EvtPrecipData{TimeEpoch: start + 90}})
+	obs(2, 0.02) // 1.2 mm/h
+	obs(3, 0.1)  // 6 mm/h
+	obs(4, 0.5)  // 30 mm/h
+	obs(5, 0)
+	obs(6, 0.01)
+	for m := 7; m < 16; m++ {
+		obs(m, 0)
+	}
+
+	if cur, ok := tracker.Current(1); !ok || cur.Total != 0.63 {
+		t.Errorf("Current(1) = %+v, %v; want an event with 0.63 mm so far", cur, ok)
+	}
+
+	obs(16, 0) // ten minutes after the last rain
+
+	if _, ok := tracker.Current(1); ok {
+		t.Error("Current(1): expected no event in progress")
+	}
+
+	want := []weatherflow.RainEvent{
+		{Type: weatherflow.RainStart, DeviceID: 1, Start: at(1).Add(30 * time.Second)},
+		{
+			Type:      weatherflow.RainEnd,
+			DeviceID:  1,
+			Start:     at(1),
+			End:       at(6),
+			Duration:  5 * time.Minute,
+			Total:     0.63,
+			PeakRate:  30,
+			Intensity: weatherflow.RainHeavy,
+		},
+	}
+	if diff := cmp.Diff(want, events, cmp.Comparer(func(a, b float64) bool { return a-b < 1e-9 && b-a < 1e-9 })); diff != "" {
+		t.Errorf("events mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRainTrackerExpire(t *testing.T) {
+	const start = 1681700000
+
+	var events []weatherflow.RainEvent
+	tracker := weatherflow.NewRainTracker(weatherflow.RainConfig{}, func(e weatherflow.RainEvent) {
+		events = append(events, e)
+	})
+
+	// A five-minute observation with rain from a device that then goes
+	// quiet, and a device that stays dry.
+	tracker.AddObsSt(1, weatherflow.ObsStData{TimeEpoch: start, ReportInterval: 5, RainAccumulated: 1})
+	tracker.AddObsSt(2, weatherflow.ObsStData{TimeEpoch: start, ReportInterval: 5})
+
+	tracker.Expire(time.Unix(start, 0).Add(29 * time.Minute))
+	if len(events) != 1 {
+		t.Fatalf("got %d events before the dry period passed, want 1", len(events))
+	}
+
+	tracker.Expire(time.Unix(start, 0).Add(30 * time.Minute))
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	end := events[1]
+	if end.Type != weatherflow.RainEnd || end.DeviceID != 1 || end.Duration != 5*time.Minute || end.PeakRate != 12 || end.Intensity != weatherflow.RainHeavy {
+		t.Errorf("unexpected end event: %+v", end)
+	}
+}
@@ -0,0 +1,75 @@
+package weatherflow
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const redacted = "REDACTED"
+
+// redactor removes API tokens from text before it is logged or returned.
+// It remembers every token the Client has used, so errors from connections
+// made before a token rotation are also covered.
+type redactor struct {
+	tokens []string
+	mu     sync.RWMutex
+}
+
+func (r *redactor) add(token string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if token == "" {
+		return
+	}
+	for _, t := range r.tokens {
+		if t == token {
+			return
+		}
+	}
+	r.tokens = append(r.tokens, token)
+}
+
+func (r *redactor) redact(s string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, t := range r.tokens {
+		s = strings.ReplaceAll(s, t, redacted)
+		// The token may also appear URL-encoded.
+		if e := url.QueryEscape(t); e != t {
+			s = strings.ReplaceAll(s, e, redacted)
+		}
+	}
+	return s
+}
+
+// redactedError wraps an error whose text may contain a token.
+type redactedError struct {
+	err error
+	r   *redactor
+}
+
+func (e *redactedError) Error() string {
+	return e.r.redact(e.err.Error())
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactError returns err with any token removed from its text, including
+// the URL recorded in a wrapped *url.Error.
+func (r *redactor) redactError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = r.redact(urlErr.URL)
+	}
+	return &redactedError{err: err, r: r}
+}
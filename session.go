@@ -0,0 +1,130 @@
+package weatherflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"nhooyr.io/websocket"
+)
+
+// session is a single WebSocket connection to the WeatherFlow server. The
+// Client normally has one session, and briefly two while rotating to a new
+// connection.
+type session struct {
+	conn        *websocket.Conn
+	ctx         context.Context
+	cancel      context.CancelFunc
+	ready       bool // connection_opened received and subscriptions sent
+	receiving   bool // at least one observation has arrived
+	lastMessage time.Time
+	lastObs     map[int]time.Time
+}
+
+// frame is a message (or failure) read from a session.
+type frame struct {
+	s    *session
+	msg  []byte
+	err  error
+	ping bool // err came from a keepalive ping rather than a read
+}
+
+// fatal reports whether the session can't continue after f.
+func (f frame) fatal() bool {
+	var protoErr *ProtocolError
+	return f.err != nil && !errors.As(f.err, &protoErr)
+}
+
+func newSession(ctx context.Context, conn *websocket.Conn) *session {
+	ctx, cancel := context.WithCancel(ctx)
+	return &session{
+		conn:        conn,
+		ctx:         ctx,
+		cancel:      cancel,
+		lastMessage: time.Now(),
+		lastObs:     make(map[int]time.Time),
+	}
+}
+
+// start launches the session's reader and, if pingInterval is non-zero, its
+// keepalive pinger. Both report to frames.
+func (s *session) start(frames chan<- frame, pingInterval time.Duration) {
+	go s.readLoop(frames)
+	if pingInterval > 0 {
+		go s.pingLoop(frames, pingInterval)
+	}
+}
+
+// close shuts the session down asynchronously: a dead peer would otherwise
+// hold us up for the duration of the close handshake timeout.
+func (s *session) close() {
+	go func() {
+		_ = s.conn.Close(websocket.StatusNormalClosure, "Closing connection")
+		s.cancel()
+	}()
+}
+
+// send delivers f unless the session has been closed.
+func (s *session) send(frames chan<- frame, f frame) {
+	select {
+	case frames <- f:
+	case <-s.ctx.Done():
+	}
+}
+
+// readLoop reads text frames from the connection and passes them to frames
+// until reading fails.
+func (s *session) readLoop(frames chan<- frame) {
+	for {
+		msgType, msg, err := s.conn.Read(s.ctx)
+		if err != nil {
+			s.send(frames, frame{s: s, err: err})
+			return
+		}
+
+		if msgType != websocket.MessageText {
+			s.send(frames, frame{s: s, err: &ProtocolError{Err: fmt.Errorf("unexpected message type: %v", msgType)}})
+			continue
+		}
+
+		s.send(frames, frame{s: s, msg: msg})
+	}
+}
+
+// pingLoop sends a keepalive ping every interval. A ping that hasn't been
+// answered by the time the next one is due is reported to frames.
+func (s *session) pingLoop(frames chan<- frame, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+
+		case <-ticker.C:
+			// Wait for the pong ourselves rather than giving Ping a deadline,
+			// which would close the connection before we could report why.
+			pong := make(chan error, 1)
+			go func() {
+				pong <- s.conn.Ping(s.ctx)
+			}()
+
+			select {
+			case err := <-pong:
+				if err != nil {
+					if s.ctx.Err() == nil {
+						s.send(frames, frame{s: s, err: err, ping: true})
+					}
+					return
+				}
+			case <-time.After(interval):
+				s.send(frames, frame{s: s, err: errPongTimeout, ping: true})
+				return
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package weatherflow
+
+import (
+	"fmt"
+	"time"
+)
+
+// ConnState is the lifecycle state of a Client's connection.
+type ConnState int
+
+const (
+	StateIdle       ConnState = iota // Start hasn't been called yet
+	StateConnecting                  // dialing the server
+	StateConnected                   // connected, waiting to subscribe
+	StateSubscribed                  // subscriptions sent, receiving data
+	StateBackingOff                  // waiting before the next connection attempt
+	StateStopped                     // Stop was called; the Client won't reconnect
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateIdle:
+		return "idle"
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateSubscribed:
+		return "subscribed"
+	case StateBackingOff:
+		return "backing off"
+	case StateStopped:
+		return "stopped"
+	default:
+		return fmt.Sprintf("ConnState(%d)", int(s))
+	}
+}
+
+// State is a snapshot of a Client's connection, as returned by Client.State
+// and passed to the OnStateChange hook.
+type State struct {
+	Conn            ConnState
+	LastError       error           // most recent error, if any
+	Reconnects      int             // number of times the connection was re-established
+	ReconnectReason ReconnectReason // why the most recent reconnect happened
+	LastMessage     time.Time       // when the last message arrived
+}
+
+// State returns the current state of the Client's connection.
+func (c *Client) State() State {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	return c.state
+}
+
+// OnStateChange registers f to be called whenever the connection state
+// changes. f is called from the Client's goroutine and should return
+// promptly.
+func (c *Client) OnStateChange(f func(State)) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	c.onStateChange = f
+}
+
+// setConnState moves the connection to state s, notifying the hook if it
+// changed.
+func (c *Client) setConnState(s ConnState) {
+	c.stateMu.Lock()
+	if c.state.Conn == s || c.state.Conn == StateStopped {
+		c.stateMu.Unlock()
+		return
+	}
+	c.state.Conn = s
+	snapshot, f := c.state, c.onStateChange
+	c.stateMu.Unlock()
+
+	if f != nil {
+		f(snapshot)
+	}
+}
+
+// recordError logs an error, counts it towards the reconnect backoff and
+// remembers it as the last error.
+func (c *Client) recordError(format string, err error) {
+	c.logf(format, err)
+
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	c.errors++
+	c.state.LastError = err
+}
+
+// recordReconnect counts a reconnect and remembers why it happened.
+func (c *Client) recordReconnect(reason ReconnectReason) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	c.state.Reconnects++
+	c.state.ReconnectReason = reason
+}
+
+// recordMessage notes the arrival of a good message, which also resets the
+// error counter.
+func (c *Client) recordMessage(now time.Time) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	c.state.LastMessage = now
+
+	// One good message resets the error counter.
+	// Set to 1 to enforce minimum backoff between reconnects.
+	c.errors = 1
+}
@@ -0,0 +1,171 @@
+package weatherflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// StationMetadata describes a station, as returned by the REST API's
+// /stations endpoint.
+type StationMetadata struct {
+	StationID   int              `json:"station_id"`
+	Name        string           `json:"name"`
+	PublicName  string           `json:"public_name"`
+	Latitude    float64          `json:"latitude"`
+	Longitude   float64          `json:"longitude"`
+	Timezone    string           `json:"timezone"`
+	StationMeta StationMeta      `json:"station_meta"`
+	Devices     []DeviceMetadata `json:"devices"`
+}
+
+type StationMeta struct {
+	Elevation float64 `json:"elevation"` // metres above sea level
+}
+
+// DeviceMetadata describes one device of a station.
+type DeviceMetadata struct {
+	DeviceID     int        `json:"device_id"`
+	SerialNumber string     `json:"serial_number"`
+	DeviceType   string     `json:"device_type"`
+	DeviceMeta   DeviceMeta `json:"device_meta"`
+}
+
+type DeviceMeta struct {
+	AGL         float64 `json:"agl"` // height above ground level, in metres
+	Name        string  `json:"name"`
+	Environment string  `json:"environment"`
+}
+
+// ParseStations decodes a response from the REST API's /stations endpoint.
+func ParseStations(data []byte) ([]StationMetadata, error) {
+	var resp struct {
+		Stations []StationMetadata `json:"stations"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, &DecodeError{Type: "stations", Err: err}
+	}
+	return resp.Stations, nil
+}
+
+// Location returns the station's time zone.
+func (s StationMetadata) Location() (*time.Location, error) {
+	if s.Timezone == "" {
+		return nil, fmt.Errorf("station %d has no time zone", s.StationID)
+	}
+	return time.LoadLocation(s.Timezone)
+}
+
+// DeviceInfo returns the DeviceInfo for each of the station's devices. If the
+// station has no time zone, their Location is nil.
+func (s StationMetadata) DeviceInfo() (map[int]DeviceInfo, error) {
+	var loc *time.Location
+	if s.Timezone != "" {
+		var err error
+		if loc, err = s.Location(); err != nil {
+			return nil, err
+		}
+	}
+
+	info := make(map[int]DeviceInfo, len(s.Devices))
+	for _, d := range s.Devices {
+		info[d.DeviceID] = DeviceInfo{
+			Location:  loc,
+			Elevation: s.StationMeta.Elevation,
+			Height:    d.DeviceMeta.AGL,
+			Latitude:  s.Latitude,
+			Longitude: s.Longitude,
+		}
+	}
+	return info, nil
+}
+
+// DeviceInfo holds the fixed properties of a device that are needed to
+// interpret its observations but aren't included in them.
+type DeviceInfo struct {
+	// Location is the station's time zone, which WeatherFlow uses for
+	// "local day" values. Nil means UTC.
+	Location *time.Location
+
+	// Elevation is the height of the ground at the station above sea level,
+	// in metres.
+	Elevation float64
+
+	// Height is the sensor's height above ground, in metres, used to
+	// correct wind speeds to the standard 10 m.
+	Height float64
+
+	// Latitude and Longitude locate the station, in degrees north and east,
+	// for solar calculations.
+	Latitude  float64
+	Longitude float64
+}
+
+// BarometerElevation returns the height of the device's barometer above sea
+// level, in metres: the station's elevation plus the device's height above
+// ground. This is the elevation to reduce its station pressure from, as
+// WeatherFlow does.
+func (d DeviceInfo) BarometerElevation() float64 {
+	return d.Elevation + d.Height
+}
+
+// LocalDay returns the start and end of the device's local day containing
+// t. See LocalDay.
+func (d DeviceInfo) LocalDay(t time.Time) (start, end time.Time) {
+	return LocalDay(t, d.Location)
+}
+
+// LocalDay returns the start (inclusive) and end (exclusive) of the day in
+// loc that contains t. These are the boundaries WeatherFlow uses for local
+// daily accumulations such as LocalDailyRainAccumulation. Days that contain
+// a daylight saving transition are 23 or 25 hours long. A nil loc means UTC.
+func LocalDay(t time.Time, loc *time.Location) (start, end time.Time) {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	t = t.In(loc)
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc), time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+}
+
+// SetDeviceInfo records the fixed properties of a device.
+func (c *Client) SetDeviceInfo(id int, info DeviceInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deviceInfo[id] = info
+}
+
+// SetDeviceLocation records a device's time zone.
+func (c *Client) SetDeviceLocation(id int, loc *time.Location) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	info := c.deviceInfo[id]
+	info.Location = loc
+	c.deviceInfo[id] = info
+}
+
+// SetStation records the properties of all of a station's devices from its
+// metadata.
+func (c *Client) SetStation(s StationMetadata) error {
+	info, err := s.DeviceInfo()
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, i := range info {
+		c.deviceInfo[id] = i
+	}
+	return nil
+}
+
+// DeviceInfo returns the recorded properties of a device, or the zero
+// DeviceInfo if none have been recorded.
+func (c *Client) DeviceInfo(id int) DeviceInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.deviceInfo[id]
+}
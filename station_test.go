@@ -0,0 +1,153 @@
+package weatherflow_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tris/weatherflow"
+)
+
+const stationsJSON = `{
+	"stations": [{
+		"station_id": 1234,
+		"name": "Home",
+		"public_name": "Main St",
+		"latitude": 37.7,
+		"longitude": -122.4,
+		"timezone": "America/Los_Angeles",
+		"station_meta": {"elevation": 25.5},
+		"devices": [
+			{"device_id": 5678, "serial_number": "HB-00000001", "device_type": "HB", "device_meta": {"agl": 0}},
+			{"device_id": 5679, "serial_number": "ST-00000001", "device_type": "ST", "device_meta": {"agl": 2.5, "name": "Tempest", "environment": "outdoor"}}
+		]
+	}],
+	"status": {"status_code": 0, "status_message": "SUCCESS"}
+}`
+
+func TestParseStations(t *testing.T) {
+	stations, err := weatherflow.ParseStations([]byte(stationsJSON))
+	if err != nil {
+		t.Fatalf("ParseStations: %v", err)
+	}
+	if len(stations) != 1 {
+		t.Fatalf("got %d stations, want 1", len(stations))
+	}
+
+	s := stations[0]
+	if s.StationID != 1234 || s.Timezone != "America/Los_Angeles" || s.StationMeta.Elevation != 25.5 {
+		t.Errorf("unexpected station: %+v", s)
+	}
+	if len(s.Devices) != 2 || s.Devices[1].DeviceMeta.AGL != 2.5 || s.Devices[1].DeviceType != "ST" {
+		t.Errorf("unexpected devices: %+v", s.Devices)
+	}
+
+	c := weatherflow.NewClient("token", nil, nil)
+	if err := c.SetStation(s); err != nil {
+		t.Fatalf("SetStation: %v", err)
+	}
+	if loc := c.DeviceInfo(5679).Location; loc == nil || loc.String() != "America/Los_Angeles" {
+		t.Errorf("DeviceInfo(5679).Location = %v, want America/Los_Angeles", loc)
+	}
+	if elev := c.DeviceInfo(5679).Elevation; elev != 25.5 {
+		t.Errorf("DeviceInfo(5679).Elevation = %v, want 25.5", elev)
+	}
+	if height := c.DeviceInfo(5679).Height; height != 2.5 {
+		t.Errorf("DeviceInfo(5679).Height = %v, want 2.5", height)
+	}
+	if elev := c.DeviceInfo(5679).BarometerElevation(); elev != 28 {
+		t.Errorf("DeviceInfo(5679).BarometerElevation() = %v, want 28", elev)
+	}
+	if info := c.DeviceInfo(5679); info.Latitude != 37.7 || info.Longitude != -122.4 {
+		t.Errorf("DeviceInfo(5679) location = %v, %v, want 37.7, -122.4", info.Latitude, info.Longitude)
+	}
+	if loc := c.DeviceInfo(9999).Location; loc != nil {
+		t.Errorf("DeviceInfo(9999).Location = %v, want nil", loc)
+	}
+
+	// A station without a time zone still has the rest of its properties.
+	s.Timezone = ""
+	c = weatherflow.NewClient("token", nil, nil)
+	if err := c.SetStation(s); err != nil {
+		t.Fatalf("SetStation without a time zone: %v", err)
+	}
+	if info := c.DeviceInfo(5679); info.Location != nil || info.Elevation != 25.5 || info.Height != 2.5 {
+		t.Errorf("DeviceInfo(5679) without a time zone = %+v, want nil Location, elevation 25.5 and height 2.5", info)
+	}
+
+	if _, err := weatherflow.ParseStations([]byte(`{"stations": 1}`)); err == nil {
+		t.Error("ParseStations: expected an error for malformed input")
+	}
+}
+
+func TestLocalDay(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		t         time.Time
+		loc       *time.Location
+		wantStart time.Time
+		wantHours float64
+	}{
+		{
+			name:      "UTC",
+			t:         time.Date(2023, 6, 1, 23, 30, 0, 0, time.UTC),
+			wantStart: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC),
+			wantHours: 24,
+		},
+		{
+			name:      "local evening is the previous UTC day",
+			t:         time.Date(2023, 6, 2, 5, 0, 0, 0, time.UTC),
+			loc:       la,
+			wantStart: time.Date(2023, 6, 1, 0, 0, 0, 0, la),
+			wantHours: 24,
+		},
+		{
+			name:      "spring forward",
+			t:         time.Date(2023, 3, 12, 12, 0, 0, 0, la),
+			loc:       la,
+			wantStart: time.Date(2023, 3, 12, 0, 0, 0, 0, la),
+			wantHours: 23,
+		},
+		{
+			name:      "fall back",
+			t:         time.Date(2023, 11, 5, 12, 0, 0, 0, la),
+			loc:       la,
+			wantStart: time.Date(2023, 11, 5, 0, 0, 0, 0, la),
+			wantHours: 25,
+		},
+	}
+
+	for _, test := range tests {
+		start, end := weatherflow.LocalDay(test.t, test.loc)
+		if !start.Equal(test.wantStart) {
+			t.Errorf("%s: start = %v, want %v", test.name, start, test.wantStart)
+		}
+		if hours := end.Sub(start).Hours(); hours != test.wantHours {
+			t.Errorf("%s: day is %v hours long, want %v", test.name, hours, test.wantHours)
+		}
+	}
+}
+
+func TestTimeAccessors(t *testing.T) {
+	obs := weatherflow.ObsStData{TimeEpoch: 1588948614}
+	if got, want := obs.Time(), time.Unix(1588948614, 0); !got.Equal(want) {
+		t.Errorf("ObsStData.Time() = %v, want %v", got, want)
+	}
+
+	rw := weatherflow.RapidWindData{TimeEpoch: 1588948615}
+	if got, want := rw.Time(), time.Unix(1588948615, 0); !got.Equal(want) {
+		t.Errorf("RapidWindData.Time() = %v, want %v", got, want)
+	}
+
+	summary := weatherflow.ObsStSummary{StrikeLastEpoch: 1588948000}
+	if got, want := summary.StrikeLastAt(), time.Unix(1588948000, 0); !got.Equal(want) {
+		t.Errorf("StrikeLastAt() = %v, want %v", got, want)
+	}
+	if got := summary.PulseAdjObAt(); !got.IsZero() {
+		t.Errorf("PulseAdjObAt() = %v, want zero time", got)
+	}
+}
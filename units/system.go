@@ -0,0 +1,137 @@
+package units
+
+import (
+	"time"
+
+	"github.com/tris/weatherflow"
+)
+
+// System is a choice of unit for each kind of quantity.
+type System struct {
+	Speed         SpeedUnit
+	Pressure      PressureUnit
+	Temperature   TemperatureUnit
+	Distance      DistanceUnit
+	Precipitation PrecipitationUnit
+}
+
+var (
+	// Metric uses the units the API reports in.
+	Metric = System{
+		Speed:         SpeedMetersPerSecond,
+		Pressure:      PressureHectopascals,
+		Temperature:   TemperatureCelsius,
+		Distance:      DistanceKilometers,
+		Precipitation: PrecipitationMillimeters,
+	}
+
+	// Imperial uses US customary units.
+	Imperial = System{
+		Speed:         SpeedMilesPerHour,
+		Pressure:      PressureInchesOfMercury,
+		Temperature:   TemperatureFahrenheit,
+		Distance:      DistanceMiles,
+		Precipitation: PrecipitationInches,
+	}
+
+	// UK uses miles per hour and miles with otherwise metric units, as UK
+	// forecasts do.
+	UK = System{
+		Speed:         SpeedMilesPerHour,
+		Pressure:      PressureHectopascals,
+		Temperature:   TemperatureCelsius,
+		Distance:      DistanceMiles,
+		Precipitation: PrecipitationMillimeters,
+	}
+)
+
+// ObsSt is a Tempest observation converted to a System. Fields that have no
+// unit (direction, humidity, illuminance, UV index and so on) are copied
+// unchanged; missing sensor readings stay nil.
+type ObsSt struct {
+	Time                            time.Time
+	WindLull                        float64
+	WindAvg                         float64
+	WindGust                        float64
+	WindDirection                   int
+	WindSampleInterval              int // seconds
+	StationPressure                 *float64
+	AirTemperature                  *float64
+	RelativeHumidity                *float64
+	Illuminance                     int
+	UV                              float64
+	SolarRadiation                  int
+	RainAccumulated                 float64
+	PrecipitationType               weatherflow.PrecipitationType
+	LightningStrikeAvgDistance      float64
+	LightningStrikeCount            int
+	Battery                         float64
+	ReportInterval                  int // minutes
+	LocalDailyRainAccumulation      float64
+	RainAccumulatedFinal            float64
+	LocalDailyRainAccumulationFinal float64
+	PrecipitationAnalysisType       weatherflow.PrecipitationAnalysisType
+
+	Units System // the units of the fields above
+}
+
+// RapidWind is a rapid wind observation converted to a System.
+type RapidWind struct {
+	Time          time.Time
+	WindSpeed     float64
+	WindDirection int
+
+	Units System // the unit of WindSpeed
+}
+
+// ObsSt converts a Tempest observation to s.
+func (s System) ObsSt(obs weatherflow.ObsStData) ObsSt {
+	speed := func(v float64) float64 { return MetersPerSecond(v).In(s.Speed) }
+	rain := func(v float64) float64 { return Millimeters(v).In(s.Precipitation) }
+
+	out := ObsSt{
+		Time:                            obs.Time(),
+		WindLull:                        speed(obs.WindLull),
+		WindAvg:                         speed(obs.WindAvg),
+		WindGust:                        speed(obs.WindGust),
+		WindDirection:                   obs.WindDirection,
+		WindSampleInterval:              obs.WindSampleInterval,
+		Illuminance:                     obs.Illuminance,
+		UV:                              obs.UV,
+		SolarRadiation:                  obs.SolarRadiation,
+		RainAccumulated:                 rain(obs.RainAccumulated),
+		PrecipitationType:               obs.PrecipitationType,
+		LightningStrikeAvgDistance:      Kilometers(float64(obs.LightningStrikeAvgDistance)).In(s.Distance),
+		LightningStrikeCount:            obs.LightningStrikeCount,
+		Battery:                         obs.Battery,
+		ReportInterval:                  obs.ReportInterval,
+		LocalDailyRainAccumulation:      rain(obs.LocalDailyRainAccumulation),
+		RainAccumulatedFinal:            rain(obs.RainAccumulatedFinal),
+		LocalDailyRainAccumulationFinal: rain(obs.LocalDailyRainAccumulationFinal),
+		PrecipitationAnalysisType:       obs.PrecipitationAnalysisType,
+		Units:                           s,
+	}
+	if obs.StationPressure != nil {
+		v := Millibars(*obs.StationPressure).In(s.Pressure)
+		out.StationPressure = &v
+	}
+	if obs.RelativeHumidity != nil {
+		v := *obs.RelativeHumidity
+		out.RelativeHumidity = &v
+	}
+	if obs.AirTemperature != nil {
+		v := Celsius(*obs.AirTemperature).In(s.Temperature)
+		out.AirTemperature = &v
+	}
+	return out
+}
+
+// RapidWind converts a rapid wind observation to s.
+func (s System) RapidWind(rw weatherflow.RapidWindData) RapidWind {
+	return RapidWind{
+		Time:          rw.Time(),
+		WindSpeed:     MetersPerSecond(rw.WindSpeed).In(s.Speed),
+		WindDirection: rw.WindDirection,
+		Units:         s,
+	}
+}
@@ -0,0 +1,248 @@
+// Package units converts WeatherFlow observations, which are always reported
+// in metric units, to other unit systems.
+package units
+
+import "fmt"
+
+// Conversion factors, from the international definitions of each unit.
+const (
+	metersPerSecondPerMPH  = 0.44704
+	metersPerSecondPerKnot = 1852.0 / 3600
+	hPaPerInHg             = 33.8638866667
+	hPaPerMmHg             = 1.33322387415
+	kmPerMile              = 1.609344
+	mmPerInch              = 25.4
+)
+
+// Speed is a wind speed, stored in metres per second.
+type Speed float64
+
+// MetersPerSecond returns a Speed of v m/s, the unit used by the API.
+func MetersPerSecond(v float64) Speed { return Speed(v) }
+
+func (s Speed) MetersPerSecond() float64   { return float64(s) }
+func (s Speed) KilometersPerHour() float64 { return float64(s) * 3.6 }
+func (s Speed) MilesPerHour() float64      { return float64(s) / metersPerSecondPerMPH }
+func (s Speed) Knots() float64             { return float64(s) / metersPerSecondPerKnot }
+
+// beaufortLimits are the lowest speeds, in m/s, of Beaufort forces 1 to 12.
+var beaufortLimits = []float64{0.3, 1.6, 3.4, 5.5, 8.0, 10.8, 13.9, 17.2, 20.8, 24.5, 28.5, 32.7}
+
+// Beaufort returns the force on the Beaufort scale, from 0 (calm) to 12
+// (hurricane force).
+func (s Speed) Beaufort() int {
+	force := 0
+	for _, limit := range beaufortLimits {
+		if float64(s) < limit {
+			break
+		}
+		force++
+	}
+	return force
+}
+
+// In returns s in unit u.
+func (s Speed) In(u SpeedUnit) float64 {
+	switch u {
+	case SpeedKilometersPerHour:
+		return s.KilometersPerHour()
+	case SpeedMilesPerHour:
+		return s.MilesPerHour()
+	case SpeedKnots:
+		return s.Knots()
+	case SpeedBeaufort:
+		return float64(s.Beaufort())
+	default:
+		return s.MetersPerSecond()
+	}
+}
+
+// Pressure is an air pressure, stored in hectopascals (equal to millibars).
+type Pressure float64
+
+// Millibars returns a Pressure of v mb, the unit used by the API.
+func Millibars(v float64) Pressure { return Pressure(v) }
+
+func (p Pressure) Millibars() float64            { return float64(p) }
+func (p Pressure) Hectopascals() float64         { return float64(p) }
+func (p Pressure) InchesOfMercury() float64      { return float64(p) / hPaPerInHg }
+func (p Pressure) MillimetersOfMercury() float64 { return float64(p) / hPaPerMmHg }
+
+// In returns p in unit u.
+func (p Pressure) In(u PressureUnit) float64 {
+	switch u {
+	case PressureInchesOfMercury:
+		return p.InchesOfMercury()
+	case PressureMillimetersOfMercury:
+		return p.MillimetersOfMercury()
+	default:
+		return p.Hectopascals()
+	}
+}
+
+// Temperature is a temperature, stored in degrees Celsius.
+type Temperature float64
+
+// Celsius returns a Temperature of v °C, the unit used by the API.
+func Celsius(v float64) Temperature { return Temperature(v) }
+
+func (t Temperature) Celsius() float64    { return float64(t) }
+func (t Temperature) Fahrenheit() float64 { return float64(t)*9/5 + 32 }
+func (t Temperature) Kelvin() float64     { return float64(t) + 273.15 }
+
+// In returns t in unit u.
+func (t Temperature) In(u TemperatureUnit) float64 {
+	switch u {
+	case TemperatureFahrenheit:
+		return t.Fahrenheit()
+	case TemperatureKelvin:
+		return t.Kelvin()
+	default:
+		return t.Celsius()
+	}
+}
+
+// Distance is a distance, such as to a lightning strike, stored in
+// kilometres.
+type Distance float64
+
+// Kilometers returns a Distance of v km, the unit used by the API.
+func Kilometers(v float64) Distance { return Distance(v) }
+
+func (d Distance) Kilometers() float64 { return float64(d) }
+func (d Distance) Miles() float64      { return float64(d) / kmPerMile }
+
+// In returns d in unit u.
+func (d Distance) In(u DistanceUnit) float64 {
+	if u == DistanceMiles {
+		return d.Miles()
+	}
+	return d.Kilometers()
+}
+
+// Precipitation is an amount of precipitation, stored in millimetres.
+type Precipitation float64
+
+// Millimeters returns a Precipitation of v mm, the unit used by the API.
+func Millimeters(v float64) Precipitation { return Precipitation(v) }
+
+func (p Precipitation) Millimeters() float64 { return float64(p) }
+func (p Precipitation) Inches() float64      { return float64(p) / mmPerInch }
+
+// In returns p in unit u.
+func (p Precipitation) In(u PrecipitationUnit) float64 {
+	if u == PrecipitationInches {
+		return p.Inches()
+	}
+	return p.Millimeters()
+}
+
+// SpeedUnit is a unit of wind speed.
+type SpeedUnit int
+
+const (
+	SpeedMetersPerSecond SpeedUnit = iota
+	SpeedKilometersPerHour
+	SpeedMilesPerHour
+	SpeedKnots
+	SpeedBeaufort
+)
+
+func (u SpeedUnit) String() string {
+	switch u {
+	case SpeedMetersPerSecond:
+		return "m/s"
+	case SpeedKilometersPerHour:
+		return "km/h"
+	case SpeedMilesPerHour:
+		return "mph"
+	case SpeedKnots:
+		return "kn"
+	case SpeedBeaufort:
+		return "Bft"
+	default:
+		return fmt.Sprintf("SpeedUnit(%d)", int(u))
+	}
+}
+
+// PressureUnit is a unit of air pressure.
+type PressureUnit int
+
+const (
+	PressureHectopascals PressureUnit = iota
+	PressureInchesOfMercury
+	PressureMillimetersOfMercury
+)
+
+func (u PressureUnit) String() string {
+	switch u {
+	case PressureHectopascals:
+		return "hPa"
+	case PressureInchesOfMercury:
+		return "inHg"
+	case PressureMillimetersOfMercury:
+		return "mmHg"
+	default:
+		return fmt.Sprintf("PressureUnit(%d)", int(u))
+	}
+}
+
+// TemperatureUnit is a unit of temperature.
+type TemperatureUnit int
+
+const (
+	TemperatureCelsius TemperatureUnit = iota
+	TemperatureFahrenheit
+	TemperatureKelvin
+)
+
+func (u TemperatureUnit) String() string {
+	switch u {
+	case TemperatureCelsius:
+		return "°C"
+	case TemperatureFahrenheit:
+		return "°F"
+	case TemperatureKelvin:
+		return "K"
+	default:
+		return fmt.Sprintf("TemperatureUnit(%d)", int(u))
+	}
+}
+
+// DistanceUnit is a unit of distance.
+type DistanceUnit int
+
+const (
+	DistanceKilometers DistanceUnit = iota
+	DistanceMiles
+)
+
+func (u DistanceUnit) String() string {
+	switch u {
+	case DistanceKilometers:
+		return "km"
+	case DistanceMiles:
+		return "mi"
+	default:
+		return fmt.Sprintf("DistanceUnit(%d)", int(u))
+	}
+}
+
+// PrecipitationUnit is a unit of precipitation depth.
+type PrecipitationUnit int
+
+const (
+	PrecipitationMillimeters PrecipitationUnit = iota
+	PrecipitationInches
+)
+
+func (u PrecipitationUnit) String() string {
+	switch u {
+	case PrecipitationMillimeters:
+		return "mm"
+	case PrecipitationInches:
+		return "in"
+	default:
+		return fmt.Sprintf("PrecipitationUnit(%d)", int(u))
+	}
+}
@@ -0,0 +1,134 @@
+package units_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tris/weatherflow"
+	"github.com/tris/weatherflow/units"
+)
+
+func approx(got, want float64) bool {
+	return math.Abs(got-want) < 0.005
+}
+
+func TestConversions(t *testing.T) {
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"m/s to mph", units.MetersPerSecond(10).MilesPerHour(), 22.369},
+		{"m/s to knots", units.MetersPerSecond(10).Knots(), 19.438},
+		{"m/s to km/h", units.MetersPerSecond(10).KilometersPerHour(), 36},
+		{"mb to inHg", units.Millibars(1013.25).InchesOfMercury(), 29.921},
+		{"mb to mmHg", units.Millibars(1013.25).MillimetersOfMercury(), 760},
+		{"°C to °F", units.Celsius(-40).Fahrenheit(), -40},
+		{"°C to °F", units.Celsius(100).Fahrenheit(), 212},
+		{"°C to K", units.Celsius(0).Kelvin(), 273.15},
+		{"km to mi", units.Kilometers(16.09344).Miles(), 10},
+		{"mm to in", units.Millimeters(25.4).Inches(), 1},
+		{"In mph", units.MetersPerSecond(10).In(units.SpeedMilesPerHour), 22.369},
+		{"In inHg", units.Millibars(1013.25).In(units.PressureInchesOfMercury), 29.921},
+	}
+
+	for _, test := range tests {
+		if !approx(test.got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, test.got, test.want)
+		}
+	}
+}
+
+func TestBeaufort(t *testing.T) {
+	tests := []struct {
+		speed float64
+		want  int
+	}{
+		{0, 0},
+		{0.2, 0},
+		{0.3, 1},
+		{3.3, 2},
+		{3.4, 3},
+		{17.1, 7},
+		{32.6, 11},
+		{32.7, 12},
+		{60, 12},
+	}
+
+	for _, test := range tests {
+		if got := units.MetersPerSecond(test.speed).Beaufort(); got != test.want {
+			t.Errorf("Beaufort(%v m/s) = %d, want %d", test.speed, got, test.want)
+		}
+	}
+}
+
+func TestSystemObsSt(t *testing.T) {
+	pressure, temp, humidity := 1013.25, 20.0, 50.0
+	obs := weatherflow.ObsStData{
+		TimeEpoch:                  1588948614,
+		WindAvg:                    10,
+		WindDirection:              180,
+		StationPressure:            &pressure,
+		AirTemperature:             &temp,
+		RelativeHumidity:           &humidity,
+		LocalDailyRainAccumulation: 25.4,
+		LightningStrikeAvgDistance: 16,
+		WindSampleInterval:         3,
+		ReportInterval:             1,
+		PrecipitationAnalysisType:  weatherflow.PrecipitationAnalysisType(1),
+	}
+
+	got := units.Imperial.ObsSt(obs)
+	if !approx(got.WindAvg, 22.369) || got.WindDirection != 180 {
+		t.Errorf("wind: got %v mph from %d°", got.WindAvg, got.WindDirection)
+	}
+	if got.StationPressure == nil || !approx(*got.StationPressure, 29.921) {
+		t.Errorf("StationPressure: got %v, want 29.921", got.StationPressure)
+	}
+	if got.AirTemperature == nil || !approx(*got.AirTemperature, 68) {
+		t.Errorf("AirTemperature: got %v, want 68", got.AirTemperature)
+	}
+	if got.RelativeHumidity == nil || *got.RelativeHumidity != 50 {
+		t.Errorf("RelativeHumidity: got %v, want 50", got.RelativeHumidity)
+	}
+	if !approx(got.LocalDailyRainAccumulation, 1) {
+		t.Errorf("LocalDailyRainAccumulation: got %v, want 1", got.LocalDailyRainAccumulation)
+	}
+	if !approx(got.LightningStrikeAvgDistance, 9.942) {
+		t.Errorf("LightningStrikeAvgDistance: got %v, want 9.942", got.LightningStrikeAvgDistance)
+	}
+	if got.WindSampleInterval != 3 || got.ReportInterval != 1 || got.PrecipitationAnalysisType != obs.PrecipitationAnalysisType {
+		t.Errorf("unitless fields: got %d, %d, %v", got.WindSampleInterval, got.ReportInterval, got.PrecipitationAnalysisType)
+	}
+	if !got.Time.Equal(obs.Time()) {
+		t.Errorf("Time: got %v, want %v", got.Time, obs.Time())
+	}
+
+	// Missing readings stay missing.
+	got = units.UK.ObsSt(weatherflow.ObsStData{})
+	if got.StationPressure != nil || got.AirTemperature != nil || got.RelativeHumidity != nil {
+		t.Errorf("missing readings: got %+v", got)
+	}
+}
+
+func TestSystemRapidWind(t *testing.T) {
+	rw := weatherflow.RapidWindData{TimeEpoch: 1588948614, WindSpeed: 10, WindDirection: 270}
+
+	tests := []struct {
+		system units.System
+		want   float64
+	}{
+		{units.Metric, 10},
+		{units.Imperial, 22.369},
+		{units.UK, 22.369},
+		{units.System{Speed: units.SpeedKnots}, 19.438},
+		{units.System{Speed: units.SpeedBeaufort}, 5},
+	}
+
+	for _, test := range tests {
+		got := test.system.RapidWind(rw)
+		if !approx(got.WindSpeed, test.want) || got.WindDirection != 270 {
+			t.Errorf("%v: got %v from %d°, want %v from 270°", test.system.Speed, got.WindSpeed, got.WindDirection, test.want)
+		}
+	}
+}
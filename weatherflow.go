@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"net/url"
 	"strconv"
 	"sync"
 	"time"
@@ -16,31 +17,87 @@ import (
 )
 
 const (
-	wfURL          = "wss://ws.weatherflow.com/swd/data?token=%s"
+	wfURL          = "wss://ws.weatherflow.com/swd/data"
 	initialBackoff = 2 // seconds (don't set below 2)
 	maxBackoff     = 32
+
+	minWatchdogInterval = 10 * time.Millisecond
+	rotationTimeout     = 30 * time.Second
 )
 
 var (
-	defaultTimeout = 12 * time.Hour
+	defaultTimeout      = 12 * time.Hour
+	defaultStaleTimeout = 2 * time.Minute
+	defaultObsTimeout   = 10 * time.Minute
+	defaultPingInterval = 30 * time.Second
+)
+
+var errPongTimeout = errors.New("timed out waiting for pong")
+
+// ReconnectReason describes what caused the Client to drop a connection.
+type ReconnectReason int
+
+const (
+	ReconnectNone         ReconnectReason = iota
+	ReconnectTimeout                      // the connection timeout elapsed and rotation failed
+	ReconnectStale                        // no message arrived within the stale timeout
+	ReconnectObsStale                     // a device sent no observation within the obs timeout
+	ReconnectPingFailed                   // a keepalive ping went unanswered
+	ReconnectReadError                    // reading from the connection failed
+	ReconnectTokenChanged                 // the token changed and rotation failed
 )
 
+func (r ReconnectReason) String() string {
+	switch r {
+	case ReconnectNone:
+		return "none"
+	case ReconnectTimeout:
+		return "connection timeout"
+	case ReconnectStale:
+		return "stale connection"
+	case ReconnectObsStale:
+		return "stale device observations"
+	case ReconnectPingFailed:
+		return "ping failed"
+	case ReconnectReadError:
+		return "read error"
+	case ReconnectTokenChanged:
+		return "token changed"
+	default:
+		return fmt.Sprintf("ReconnectReason(%d)", int(r))
+	}
+}
+
 // Client represents a client for the WeatherFlow Smart Weather API.
 type Client struct {
-	deviceIDs map[int]struct{}
-	url       string
-	timeout   time.Duration
-	logf      Logf
-	conn      *websocket.Conn
-	errors    int
-	ready     bool
-	ctx       context.Context
-	cancel    context.CancelFunc
-	mu        sync.RWMutex
+	deviceIDs     map[int]struct{}
+	deviceInfo    map[int]DeviceInfo
+	url           string
+	token         string
+	rotate        chan ReconnectReason
+	redactor      redactor
+	dialOpts      *websocket.DialOptions
+	timeout       time.Duration
+	staleTimeout  time.Duration
+	obsTimeout    time.Duration
+	pingInterval  time.Duration
+	logf          Logf
+	active        *session
+	pending       *session
+	errors        int
+	state         State
+	stateMu       sync.Mutex
+	onStateChange func(State)
+	onDeviceError func(*DeviceError)
+	removeDenied  bool
+	ctx           context.Context
+	cancel        context.CancelFunc
+	mu            sync.RWMutex
 }
 
 // NewClient creates a new Client with the given API token, optional connection
-// timeout, and an optional log function (if nil, logs will be discarded).
+// timeout, and an optional log function (if nil, logs will be discarded). The
+// token is redacted from everything logged and from returned errors.
 func NewClient(token string, timeout *time.Duration, logf Logf) *Client {
 	if logf == nil {
 		logf = func(format string, args ...interface{}) {} // discard
@@ -53,22 +110,115 @@ func NewClient(token string, timeout *time.Duration, logf Logf) *Client {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	c := &Client{
-		deviceIDs: make(map[int]struct{}),
-		url:       fmt.Sprintf(wfURL, token),
-		timeout:   *timeout,
-		logf:      logf,
-		ctx:       ctx,
-		cancel:    cancel,
+		deviceIDs:    make(map[int]struct{}),
+		deviceInfo:   make(map[int]DeviceInfo),
+		url:          wfURL,
+		token:        token,
+		rotate:       make(chan ReconnectReason, 1),
+		timeout:      *timeout,
+		staleTimeout: defaultStaleTimeout,
+		obsTimeout:   defaultObsTimeout,
+		pingInterval: defaultPingInterval,
+		logf:         logf,
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+
+	c.redactor.add(token)
+	c.logf = func(format string, args ...interface{}) {
+		logf("%s", c.redactor.redact(fmt.Sprintf(format, args...)))
 	}
 
 	return c
 }
 
-// SetURL overrides the server URL (for testing).
+// SetURL overrides the server URL (for testing). The token is added as a
+// query parameter when connecting.
 func (c *Client) SetURL(url string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.url = url
 }
 
+// SetToken changes the API token. If the Client is connected, a new
+// connection is made with the new token and swapped in without dropping
+// observations, as with the connection timeout.
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	c.token = token
+	c.mu.Unlock()
+	c.redactor.add(token)
+
+	select {
+	case c.rotate <- ReconnectTokenChanged:
+	default:
+		// A rotation is already queued, and will pick up the new token.
+	}
+}
+
+// dialURL returns the URL to connect to, including the token.
+func (c *Client) dialURL() (string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	u, err := url.Parse(c.url)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("token", c.token)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// SetStaleTimeout sets how long a connection with subscribed devices may go
+// without receiving any message before it is reconnected. Zero disables the
+// check. How often it is checked is set when each connection is made, so
+// a shorter timeout takes full effect from the next connection.
+func (c *Client) SetStaleTimeout(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.staleTimeout = d
+}
+
+// SetObsTimeout sets how long any subscribed device may go without sending
+// an observation before the connection is reconnected. Zero disables the
+// check. How often it is checked is set when each connection is made, so
+// a shorter timeout takes full effect from the next connection.
+func (c *Client) SetObsTimeout(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.obsTimeout = d
+}
+
+// SetPingInterval sets how often a keepalive ping is sent. A ping that
+// hasn't been answered within the interval causes a reconnect. Zero
+// disables pings. A change applies from the next connection.
+func (c *Client) SetPingInterval(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pingInterval = d
+}
+
+// OnDeviceError registers f to be called when the server reports an error
+// for a device, such as the token not being permitted to access it. Such
+// obs_st messages are not passed to the Start callback. f is called from the
+// Client's goroutine and should return promptly. If the device is to be
+// removed (see SetRemoveDeniedDevices), it already has been when f is called.
+func (c *Client) OnDeviceError(f func(*DeviceError)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onDeviceError = f
+}
+
+// SetRemoveDeniedDevices controls whether a device is automatically removed
+// when the server reports that the token isn't permitted to access it.
+func (c *Client) SetRemoveDeniedDevices(remove bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeDenied = remove
+}
+
 // AddDevice subscribes to wind events for a device ID.
 func (c *Client) AddDevice(id int) {
 	c.mu.Lock()
@@ -76,8 +226,8 @@ func (c *Client) AddDevice(id int) {
 
 	c.deviceIDs[id] = struct{}{}
 
-	if c.conn != nil && c.ready {
-		c.sendListenStart(id)
+	for _, s := range c.sessions() {
+		c.sendListenStart(s, id)
 	}
 }
 
@@ -88,11 +238,31 @@ func (c *Client) RemoveDevice(id int) {
 
 	delete(c.deviceIDs, id)
 
-	if c.conn != nil && c.ready {
-		c.sendListenStop(id)
+	for _, s := range c.sessions() {
+		c.sendListenStop(s, id)
 	}
 }
 
+// sessions returns the sessions that are ready for subscriptions. c.mu must
+// be held.
+func (c *Client) sessions() []*session {
+	var ready []*session
+	for _, s := range []*session{c.active, c.pending} {
+		if s != nil && s.ready {
+			ready = append(ready, s)
+		}
+	}
+	return ready
+}
+
+// hasDevice reports whether the Client is subscribed to a device.
+func (c *Client) hasDevice(id int) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.deviceIDs[id]
+	return ok
+}
+
 // DeviceCount returns a count of monitored devices.
 func (c *Client) DeviceCount() int {
 	c.mu.RLock()
@@ -104,120 +274,470 @@ func (c *Client) DeviceCount() int {
 // incoming messages.
 func (c *Client) Start(onMessage func(Message)) {
 	go func() {
+		defer c.setConnState(StateStopped)
 		defer c.cancel()
 
 		for {
+			if c.ctx.Err() != nil {
+				return
+			}
+
+			c.handleBackoff()
+			c.setConnState(StateConnecting)
+
+			// This connection will use the latest token anyway.
 			select {
-			case <-c.ctx.Done():
-				// Close the WebSocket connection and return
-				// Probably redundant?
-				if c.conn != nil {
-					c.logf("Disconnecting from WeatherFlow")
-					_ = c.conn.Close(websocket.StatusNormalClosure, "Closing connection")
+			case <-c.rotate:
+			default:
+			}
+
+			s, err := c.dial()
+			if err != nil {
+				if c.ctx.Err() != nil {
+					return
+				}
+				c.recordError("Error connecting to WeatherFlow: %v", err)
+
+				var authErr *AuthError
+				if errors.As(err, &authErr) {
+					c.logf("Giving up: WeatherFlow rejected the token")
+					return
 				}
-				c.ready = false
+				continue
+			}
+
+			reason := c.serve(s, onMessage)
+			if c.ctx.Err() != nil {
+				c.logf("Disconnecting from WeatherFlow")
 				return
+			}
+			c.logf("Reconnecting to WeatherFlow: %s", reason)
+			c.recordReconnect(reason)
+		}
+	}()
+}
 
-			default:
-				c.handleBackoff()
-				c.logf("Connecting to WeatherFlow")
-				conn, _, err := websocket.Dial(c.ctx, c.url, nil)
-				if err != nil {
-					c.logf("Error connecting to WeatherFlow: %v", err)
-					c.errors++
-					continue
+// dial opens a new session with the WeatherFlow server.
+func (c *Client) dial() (*session, error) {
+	c.logf("Connecting to WeatherFlow")
+	u, err := c.dialURL()
+	if err != nil {
+		return nil, &NetworkError{Op: "dial", Err: err}
+	}
+
+	// websocket.Dial fills in defaults on the options it is given, so pass
+	// a copy.
+	var opts *websocket.DialOptions
+	c.mu.RLock()
+	if c.dialOpts != nil {
+		o := *c.dialOpts
+		opts = &o
+	}
+	c.mu.RUnlock()
+
+	conn, resp, err := websocket.Dial(c.ctx, u, opts)
+	if err != nil {
+		return nil, dialError(resp, c.redactor.redactError(err))
+	}
+	return newSession(c.ctx, conn), nil
+}
+
+// serve processes messages from s until the connection fails or the
+// watchdog decides it has gone stale. When the connection timeout elapses,
+// a replacement is opened and subscribed before s is closed, so rotation
+// doesn't drop any observations.
+func (c *Client) serve(s *session, onMessage func(Message)) ReconnectReason {
+	frames := make(chan frame)
+	dialed := make(chan *session, 1)
+	rotating := false
+
+	// Settings are read once per connection, so changes apply to the next.
+	c.mu.Lock()
+	c.active = s
+	connTimeout, pingInterval, watchdogInterval := c.timeout, c.pingInterval, c.watchdogInterval()
+	c.mu.Unlock()
+	c.setConnState(StateConnected)
+	s.start(frames, pingInterval)
+
+	defer func() {
+		c.mu.Lock()
+		if c.active != nil {
+			c.active.close()
+		}
+		if c.pending != nil {
+			c.pending.close()
+		}
+		c.active, c.pending = nil, nil
+		c.mu.Unlock()
+
+		if rotating {
+			// Don't leak a connection that is still being dialed.
+			go func() {
+				if ns := <-dialed; ns != nil {
+					ns.close()
 				}
+			}()
+		}
+	}()
 
-				// Start a ticker for the connection timeout
-				ticker := time.NewTicker(c.timeout)
-				defer ticker.Stop()
-
-				defer conn.Close(websocket.StatusInternalError, "closing connection")
-				c.conn = conn
-
-				// Read messages from the WebSocket connection
-				readLoop:
-				for {
-					select {
-					case <-ticker.C:
-						c.logf("Connection timeout")
-						break readLoop
-
-					default:
-						msgType, msg, err := conn.Read(c.ctx)
-						if err != nil {
-							if !errors.Is(err, context.Canceled) {
-								c.logf("Error reading message: %v", err)
-								c.errors++
-							}
-							break readLoop
-						}
+	// Start a timer for the connection timeout
+	timeout := time.NewTimer(connTimeout)
+	defer timeout.Stop()
+
+	watchdog := time.NewTicker(watchdogInterval)
+	defer watchdog.Stop()
+
+	var h *handover
+	// What the last handover delivered, in case the new connection was
+	// behind the old one and repeats some of it after the switch.
+	var overlap *dedup
+	var rotateDeadline <-chan time.Time
+	var rotateReason ReconnectReason
+
+	rotate := func(reason ReconnectReason) {
+		rotateReason = reason
+		rotating = true
+		go func() {
+			ns, err := c.dial()
+			if err != nil && c.ctx.Err() == nil {
+				c.recordError("Error connecting to WeatherFlow: %v", err)
+			}
+			dialed <- ns
+		}()
+	}
 
-						if msgType != websocket.MessageText {
-							c.logf("Error resolving unexpected message type: %v", msgType)
-							c.errors++
-							continue
-						}
+	for {
+		// Only one rotation at a time; further requests wait their turn.
+		timeoutC, rotateC := timeout.C, c.rotate
+		if rotating || h != nil {
+			timeoutC, rotateC = nil, nil
+		}
 
-						// Parse the message
-						m, err := UnmarshalMessage(msg)
-						if err != nil {
-							c.logf("Error unmarshalling message: %v", err)
-							c.errors++
-							continue
-						}
+		select {
+		case <-c.ctx.Done():
+			return ReconnectNone
 
-						// Handle the message
-						switch t := m.(type) {
-						case *MessageRapidWind:
-							onMessage(m)
+		case <-timeoutC:
+			c.logf("Connection timeout, rotating connection")
+			rotate(ReconnectTimeout)
 
-						case *MessageObsSt:
-							onMessage(m)
+		case reason := <-rotateC:
+			c.logf("Rotating connection: %s", reason)
+			rotate(reason)
 
-						case *MessageAck:
-							c.logf("Received ack: %s", t.ID)
+		case ns := <-dialed:
+			rotating = false
+			if ns == nil {
+				return rotateReason
+			}
+			h, overlap = newHandover(), nil
+			rotateDeadline = time.After(rotationTimeout)
+			c.mu.Lock()
+			c.pending = ns
+			pingInterval = c.pingInterval
+			c.mu.Unlock()
+			ns.start(frames, pingInterval)
+
+		case <-rotateDeadline:
+			c.mu.RLock()
+			ready := c.pending.ready
+			c.mu.RUnlock()
+			if !ready {
+				c.logf("Replacement connection didn't open, reconnecting")
+				return rotateReason
+			}
 
-						case *MessageConnectionOpened:
-							// Subscribe to wind events
-							c.mu.Lock()
-							c.ready = true
-							for id, _ := range c.deviceIDs {
-								c.sendListenStart(id)
-							}
-							c.mu.Unlock()
+			// The old connection has been quiet, so there's nothing to
+			// overlap with.
+			c.promote(h, onMessage)
+			resetTimer(timeout, connTimeout)
+			h, overlap, rotateDeadline = nil, h.seen, nil
+
+		case f := <-frames:
+			c.mu.RLock()
+			active, pending := c.active, c.pending
+			c.mu.RUnlock()
+
+			switch {
+			case f.s != active && f.s != pending:
+				// Left over from a connection that has been closed.
+
+			case f.err != nil && !f.fatal():
+				c.recordError("Error resolving unexpected message: %v", f.err)
+
+			case f.s == active && f.err != nil:
+				if pending != nil && pending.receiving {
+					c.logf("Error on old connection during rotation: %v", f.err)
+					c.promote(h, onMessage)
+					resetTimer(timeout, connTimeout)
+					h, overlap, rotateDeadline = nil, h.seen, nil
+					continue
+				}
+				return c.frameError(f)
 
-						default:
-							c.logf("Received unknown message: %v", t)
+			case f.s == pending && f.err != nil:
+				c.logf("Error on replacement connection: %v", f.err)
+				return rotateReason
+
+			case f.s == active:
+				c.handleFrame(f.s, f.msg, func(m Message) {
+					if h == nil {
+						if overlap != nil {
+							m = overlap.filter(m, false)
+						}
+						if m != nil {
+							onMessage(m)
 						}
+						return
+					}
+					for _, m := range h.fromOld(m) {
+						onMessage(m)
+					}
+				})
 
-						// One good message resets the error counter.
-						// Set to 1 to enforce minimum backoff between reconnects.
-						c.errors = 1
+			case f.s == pending:
+				c.handleFrame(f.s, f.msg, func(m Message) {
+					for _, m := range h.fromNew(m) {
+						onMessage(m)
 					}
-				}
+				})
+			}
+
+			// Switch over once the replacement has caught up, or straight
+			// away if there's nothing to wait for.
+			if pending != nil && pending.ready && (h.done() || c.DeviceCount() == 0) {
+				c.promote(h, onMessage)
+				resetTimer(timeout, connTimeout)
+				h, overlap, rotateDeadline = nil, h.seen, nil
+			}
+
+		case now := <-watchdog.C:
+			if reason := c.checkStale(now); reason != ReconnectNone {
+				return reason
 			}
 		}
-	}()
+	}
+}
+
+// resetTimer resets t to fire after d, discarding any pending expiry.
+func resetTimer(t *time.Timer, d time.Duration) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+	t.Reset(d)
+}
+
+// promote makes the pending session active, delivering anything the
+// handover was still holding back, and closes the old one.
+func (c *Client) promote(h *handover, onMessage func(Message)) {
+	for _, m := range h.flush() {
+		onMessage(m)
+	}
+
+	c.mu.Lock()
+	c.active.close()
+	c.active, c.pending = c.pending, nil
+	c.mu.Unlock()
+
+	c.logf("Switched to new connection")
+}
+
+// frameError logs a failed session and reports why it was dropped.
+func (c *Client) frameError(f frame) ReconnectReason {
+	if f.ping {
+		c.recordError("Error pinging WeatherFlow: %v", &NetworkError{Op: "ping", Err: f.err})
+		return ReconnectPingFailed
+	}
+
+	if !errors.Is(f.err, context.Canceled) {
+		c.recordError("Error reading message: %v", &NetworkError{Op: "read", Err: f.err})
+	}
+	return ReconnectReadError
+}
+
+// handleFrame parses a single message from s and dispatches it. Observations
+// are passed to deliver.
+func (c *Client) handleFrame(s *session, msg []byte, deliver func(Message)) {
+	now := time.Now()
+	c.mu.Lock()
+	s.lastMessage = now
+	c.mu.Unlock()
+
+	// Parse the message
+	m, err := UnmarshalMessageFrom(msg, OriginWebSocket, now)
+	if err != nil {
+		c.recordError("Error unmarshalling message: %v", err)
+		return
+	}
+
+	// Handle the message
+	switch t := m.(type) {
+	case *MessageRapidWind:
+		s.receiving = true
+		deliver(m)
+
+	case *MessageObsSt:
+		if !t.Status.OK() {
+			c.deviceError(&DeviceError{
+				DeviceID:      t.DeviceID,
+				StatusCode:    t.Status.StatusCode,
+				StatusMessage: t.Status.StatusMessage,
+			})
+			break
+		}
+
+		c.mu.Lock()
+		s.lastObs[t.DeviceID] = now
+		c.mu.Unlock()
+		s.receiving = true
+		deliver(m)
+
+	case *MessageEvtPrecip, *MessageEvtStrike:
+		deliver(m)
+
+	case *MessageError:
+		id, ok := t.GetDeviceID()
+		if !ok {
+			c.recordError("Error from WeatherFlow: %v", &ProtocolError{
+				Err: fmt.Errorf("%s (status %d)", t.Status.StatusMessage, t.Status.StatusCode),
+			})
+			break
+		}
+		c.deviceError(&DeviceError{
+			DeviceID:      id,
+			StatusCode:    t.Status.StatusCode,
+			StatusMessage: t.Status.StatusMessage,
+		})
+
+	case *MessageAck:
+		c.logf("Received ack: %s", t.ID)
+
+	case *MessageConnectionOpened:
+		// Subscribe to wind events
+		c.mu.Lock()
+		s.ready = true
+		for id := range c.deviceIDs {
+			c.sendListenStart(s, id)
+		}
+		c.mu.Unlock()
+		c.setConnState(StateSubscribed)
+
+	default:
+		c.logf("Received unknown message: %v", t)
+	}
+
+	c.recordMessage(now)
+}
+
+// deviceError reports a per-device error to the OnDeviceError hook, and
+// removes the device if it can never succeed and SetRemoveDeniedDevices is
+// enabled.
+func (c *Client) deviceError(err *DeviceError) {
+	c.logf("Error from WeatherFlow: %v", err)
+
+	c.mu.Lock()
+	f, remove := c.onDeviceError, c.removeDenied
+	if err.NotPermitted() {
+		// Don't let the watchdog wait for observations that won't come.
+		for _, s := range []*session{c.active, c.pending} {
+			if s != nil {
+				delete(s.lastObs, err.DeviceID)
+			}
+		}
+	}
+	c.mu.Unlock()
+
+	if remove && err.NotPermitted() {
+		c.logf("Removing device %d", err.DeviceID)
+		c.RemoveDevice(err.DeviceID)
+	}
+
+	if f != nil {
+		f(err)
+	}
+}
+
+// checkStale reports whether the connection should be dropped because no
+// message, or no observation from a subscribed device, arrived in time.
+func (c *Client) checkStale(now time.Time) ReconnectReason {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	s := c.active
+
+	// Nothing is expected to arrive until at least one device is subscribed.
+	if s == nil || !s.ready || len(c.deviceIDs) == 0 {
+		return ReconnectNone
+	}
+
+	if c.staleTimeout > 0 && now.Sub(s.lastMessage) > c.staleTimeout {
+		c.logf("No message received for %v", now.Sub(s.lastMessage).Round(time.Second))
+		return ReconnectStale
+	}
+
+	if c.obsTimeout > 0 {
+		for id := range c.deviceIDs {
+			last, ok := s.lastObs[id]
+			if ok && now.Sub(last) > c.obsTimeout {
+				c.logf("No observation received from device %d for %v", id, now.Sub(last).Round(time.Second))
+				return ReconnectObsStale
+			}
+		}
+	}
+
+	return ReconnectNone
+}
+
+// watchdogInterval returns how often checkStale should run. c.mu must be
+// held.
+func (c *Client) watchdogInterval() time.Duration {
+	interval := c.timeout
+	for _, d := range []time.Duration{c.staleTimeout, c.obsTimeout} {
+		if d > 0 && d < interval {
+			interval = d
+		}
+	}
+
+	interval /= 4
+	if interval < minWatchdogInterval {
+		interval = minWatchdogInterval
+	}
+	return interval
 }
 
 // handleBackoff sleeps for up to maxBackoff seconds to avoid overwhelming
 // the API when it's having issues.
 func (c *Client) handleBackoff() {
+	c.stateMu.Lock()
+	n := c.errors
+	c.stateMu.Unlock()
+
 	// No backoff if we haven't gotten any errors yet.
-	if c.errors == 0 {
+	if n == 0 {
 		return
 	}
 
-	backoff := math.Min(math.Pow(initialBackoff, float64(c.errors)), maxBackoff)
-	c.logf("sleeping for %.0f sec after %d error(s)", backoff, c.errors)
-	time.Sleep(time.Duration(backoff) * time.Second)
+	c.setConnState(StateBackingOff)
+	backoff := math.Min(math.Pow(initialBackoff, float64(n)), maxBackoff)
+	c.logf("sleeping for %.0f sec after %d error(s)", backoff, n)
+	select {
+	case <-time.After(time.Duration(backoff) * time.Second):
+	case <-c.ctx.Done():
+	}
 }
 
-// sendListenStart subscribes to wind observation events.
-func (c *Client) sendListenStart(id int) {
+// sendListenStart subscribes s to wind observation events.
+func (c *Client) sendListenStart(s *session, id int) {
 	c.logf("Listening to wind events from device %d", id)
 
+	// Give the device a full obs timeout to send its first observation.
+	if _, ok := s.lastObs[id]; !ok {
+		s.lastObs[id] = time.Now()
+	}
+
 	idStr := strconv.Itoa(id)
 
 	startMessage := map[string]interface{}{
@@ -232,23 +752,23 @@ func (c *Client) sendListenStart(id int) {
 		"id":        "listen_rapid_start_" + idStr,
 	}
 
-	err := wsjson.Write(c.ctx, c.conn, startMessage)
+	err := wsjson.Write(s.ctx, s.conn, startMessage)
 	if err != nil {
-		c.logf("Error sending start message: %v", err)
-		c.errors++
+		c.recordError("Error sending start message: %v", &NetworkError{Op: "write", Err: err})
 	}
 
-	err = wsjson.Write(c.ctx, c.conn, rapidStartMessage)
+	err = wsjson.Write(s.ctx, s.conn, rapidStartMessage)
 	if err != nil {
-		c.logf("Error sending rapid start message: %v", err)
-		c.errors++
+		c.recordError("Error sending rapid start message: %v", &NetworkError{Op: "write", Err: err})
 	}
 }
 
-// sendListenStop unsubscribes from wind observation events.
-func (c *Client) sendListenStop(id int) {
+// sendListenStop unsubscribes s from wind observation events.
+func (c *Client) sendListenStop(s *session, id int) {
 	c.logf("Stopping wind events from device %d", id)
 
+	delete(s.lastObs, id)
+
 	idStr := strconv.Itoa(id)
 
 	stopMessage := map[string]interface{}{
@@ -263,19 +783,19 @@ func (c *Client) sendListenStop(id int) {
 		"id":        "listen_rapid_stop_" + idStr,
 	}
 
-	err := wsjson.Write(c.ctx, c.conn, stopMessage)
+	err := wsjson.Write(s.ctx, s.conn, stopMessage)
 	if err != nil {
-		c.logf("Error sending stop message: %v", err)
-		c.errors++
+		c.recordError("Error sending stop message: %v", &NetworkError{Op: "write", Err: err})
 	}
 
-	err = wsjson.Write(c.ctx, c.conn, rapidStopMessage)
+	err = wsjson.Write(s.ctx, s.conn, rapidStopMessage)
 	if err != nil {
-		c.logf("Error sending rapid stop message: %v", err)
-		c.errors++
+		c.recordError("Error sending rapid stop message: %v", &NetworkError{Op: "write", Err: err})
 	}
 }
 
+// Stop closes the connection and stops the Client from reconnecting.
 func (c *Client) Stop() {
 	c.cancel()
+	c.setConnState(StateStopped)
 }
@@ -2,9 +2,12 @@ package weatherflow_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -14,8 +17,33 @@ import (
 )
 
 func startMockServer() (string, func()) {
+	return startMockServerWithHandler(mockServerHandler)
+}
+
+// testLogf returns a Logf that logs to t until stop is called, so that
+// goroutines a Client leaves winding down after Stop don't log after the test
+// has completed.
+func testLogf(t *testing.T) (logf weatherflow.Logf, stop func()) {
+	var mu sync.Mutex
+	stopped := false
+	logf = func(format string, args ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !stopped {
+			t.Logf(format, args...)
+		}
+	}
+	stop = func() {
+		mu.Lock()
+		defer mu.Unlock()
+		stopped = true
+	}
+	return logf, stop
+}
+
+func startMockServerWithHandler(handler http.HandlerFunc) (string, func()) {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/ws", mockServerHandler)
+	mux.HandleFunc("/ws", handler)
 	server := &http.Server{
 		Handler: mux,
 	}
@@ -148,3 +176,725 @@ func TestNewClient(t *testing.T) {
 	// Stop the client
 	client.Stop()
 }
+
+func TestWatchdogReconnect(t *testing.T) {
+	tests := []struct {
+		name       string
+		configure  func(*weatherflow.Client)
+		handler    func(ctx context.Context, c *websocket.Conn)
+		wantReason weatherflow.ReconnectReason
+	}{
+		{
+			name: "stale connection",
+			configure: func(client *weatherflow.Client) {
+				client.SetStaleTimeout(200 * time.Millisecond)
+			},
+			handler: func(ctx context.Context, c *websocket.Conn) {
+				// Swallow subscriptions without ever sending data.
+				for {
+					if _, _, err := c.Read(ctx); err != nil {
+						return
+					}
+				}
+			},
+			wantReason: weatherflow.ReconnectStale,
+		},
+		{
+			name: "stale device observations",
+			configure: func(client *weatherflow.Client) {
+				client.SetObsTimeout(200 * time.Millisecond)
+			},
+			handler: func(ctx context.Context, c *websocket.Conn) {
+				// Keep the connection busy with acks, but never send obs_st.
+				for {
+					var msg map[string]interface{}
+					if err := wsjson.Read(ctx, c, &msg); err != nil {
+						return
+					}
+					go func(id string) {
+						for {
+							if err := wsjson.Write(ctx, c, map[string]string{"type": "ack", "id": id}); err != nil {
+								return
+							}
+							time.Sleep(20 * time.Millisecond)
+						}
+					}(msg["id"].(string))
+				}
+			},
+			wantReason: weatherflow.ReconnectObsStale,
+		},
+		{
+			name: "unanswered ping",
+			configure: func(client *weatherflow.Client) {
+				client.SetPingInterval(200 * time.Millisecond)
+			},
+			handler: func(ctx context.Context, c *websocket.Conn) {
+				// Never read, so pings are never answered.
+				<-ctx.Done()
+			},
+			wantReason: weatherflow.ReconnectPingFailed,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			connections := make(chan struct{}, 10)
+			done := make(chan struct{})
+			defer close(done)
+
+			url, stopServer := startMockServerWithHandler(func(w http.ResponseWriter, r *http.Request) {
+				c, err := websocket.Accept(w, r, nil)
+				if err != nil {
+					return
+				}
+				defer c.Close(websocket.StatusInternalError, "Internal error")
+				connections <- struct{}{}
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				go func() {
+					select {
+					case <-done:
+					case <-r.Context().Done():
+					}
+					cancel()
+				}()
+
+				if err := wsjson.Write(ctx, c, map[string]string{"type": "connection_opened"}); err != nil {
+					return
+				}
+				test.handler(ctx, c)
+			})
+			defer stopServer()
+
+			client := weatherflow.NewClient("your_token", nil, t.Logf)
+			client.SetURL(url)
+			test.configure(client)
+			client.AddDevice(12345)
+			client.Start(func(msg weatherflow.Message) {})
+			defer client.Stop()
+
+			for i := 0; i < 2; i++ {
+				select {
+				case <-connections:
+				case <-time.After(5 * time.Second):
+					t.Fatalf("Timed out waiting for connection %d", i+1)
+				}
+			}
+
+			if got := client.State().ReconnectReason; got != test.wantReason {
+				t.Errorf("ReconnectReason = %v, want %v", got, test.wantReason)
+			}
+		})
+	}
+}
+
+func TestSettingsWhileConnected(t *testing.T) {
+	url, stopServer := startMockServer()
+	defer stopServer()
+
+	logf, stopLog := testLogf(t)
+	defer stopLog()
+	timeout := 50 * time.Millisecond
+	client := weatherflow.NewClient("your_token", &timeout, logf)
+	client.SetURL(url)
+	client.AddDevice(12345)
+	client.Start(func(msg weatherflow.Message) {})
+	defer client.Stop()
+
+	// Change the settings while connections are being made and rotated;
+	// run with -race.
+	deadline := time.Now().Add(300 * time.Millisecond)
+	for d := time.Second; time.Now().Before(deadline); d += time.Millisecond {
+		client.SetPingInterval(d)
+		client.SetStaleTimeout(d)
+		client.SetObsTimeout(d)
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRotationWithoutGaps(t *testing.T) {
+	// Every connection subscribed to rapid wind receives the same sequence
+	// of observations, as with the real server.
+	var mu sync.Mutex
+	subscribers := make(map[chan int]struct{})
+	connections := make(chan struct{}, 100)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for epoch := 1; ; epoch++ {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			mu.Lock()
+			for ch := range subscribers {
+				select {
+				case ch <- epoch:
+				default:
+				}
+			}
+			mu.Unlock()
+		}
+	}()
+
+	url, stopServer := startMockServerWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close(websocket.StatusInternalError, "Internal error")
+		connections <- struct{}{}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		ch := make(chan int, 1000)
+		defer func() {
+			mu.Lock()
+			delete(subscribers, ch)
+			mu.Unlock()
+		}()
+
+		go func() {
+			defer cancel()
+			for {
+				var msg map[string]interface{}
+				if err := wsjson.Read(ctx, c, &msg); err != nil {
+					return
+				}
+				if msg["type"] == "listen_rapid_start" {
+					mu.Lock()
+					subscribers[ch] = struct{}{}
+					mu.Unlock()
+				}
+			}
+		}()
+
+		if err := wsjson.Write(ctx, c, map[string]string{"type": "connection_opened"}); err != nil {
+			return
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			case epoch := <-ch:
+				err := wsjson.Write(ctx, c, map[string]interface{}{
+					"type":      "rapid_wind",
+					"device_id": 12345,
+					"ob":        []interface{}{epoch, 1.0, 180},
+				})
+				if err != nil {
+					return
+				}
+			}
+		}
+	})
+	defer stopServer()
+
+	timeout := 200 * time.Millisecond
+	client := weatherflow.NewClient("your_token", &timeout, t.Logf)
+	client.SetURL(url)
+	client.AddDevice(12345)
+
+	epochs := make(chan int, 1000)
+	client.Start(func(msg weatherflow.Message) {
+		if m, ok := msg.(*weatherflow.MessageRapidWind); ok {
+			epochs <- m.Ob.TimeEpoch
+		}
+	})
+	defer client.Stop()
+
+	deadline := time.After(5 * time.Second)
+	for i := 0; i < 4; i++ {
+		select {
+		case <-connections:
+		case <-deadline:
+			t.Fatalf("Timed out waiting for connection %d", i+1)
+		}
+	}
+	client.Stop()
+
+	var got []int
+	for {
+		select {
+		case epoch := <-epochs:
+			got = append(got, epoch)
+			continue
+		case <-time.After(100 * time.Millisecond):
+		}
+		break
+	}
+
+	if len(got) < 2 {
+		t.Fatalf("Received %d observations, want several", len(got))
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i] != got[i-1]+1 {
+			t.Fatalf("Observation %d has epoch %d after %d, want a contiguous sequence", i, got[i], got[i-1])
+		}
+	}
+}
+
+func TestRotationDeliversEventsOnce(t *testing.T) {
+	// Rapid wind goes to every subscribed connection, as in
+	// TestRotationWithoutGaps. Each time a connection subscribes while
+	// another is open, a strike is sent on all of them, so both sides of
+	// the handover carry it.
+	var mu sync.Mutex
+	subscribers := make(map[chan interface{}]struct{})
+	connections := make(chan struct{}, 100)
+	done := make(chan struct{})
+	defer close(done)
+
+	send := func(msg interface{}) {
+		for ch := range subscribers {
+			select {
+			case ch <- msg:
+			default:
+			}
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for epoch := 1; ; epoch++ {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			mu.Lock()
+			send(map[string]interface{}{
+				"type":      "rapid_wind",
+				"device_id": 12345,
+				"ob":        []interface{}{epoch, 1.0, 180},
+			})
+			mu.Unlock()
+		}
+	}()
+
+	strikes := 0
+	url, stopServer := startMockServerWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close(websocket.StatusInternalError, "Internal error")
+		connections <- struct{}{}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		ch := make(chan interface{}, 1000)
+		defer func() {
+			mu.Lock()
+			delete(subscribers, ch)
+			mu.Unlock()
+		}()
+
+		go func() {
+			defer cancel()
+			for {
+				var msg map[string]interface{}
+				if err := wsjson.Read(ctx, c, &msg); err != nil {
+					return
+				}
+				if msg["type"] == "listen_start" {
+					mu.Lock()
+					subscribers[ch] = struct{}{}
+					if len(subscribers) > 1 {
+						strikes++
+						send(map[string]interface{}{
+							"type":      "evt_strike",
+							"device_id": 12345,
+							"evt":       []interface{}{1681701864 + strikes, 27, 3848},
+						})
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+
+		if err := wsjson.Write(ctx, c, map[string]string{"type": "connection_opened"}); err != nil {
+			return
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			case msg := <-ch:
+				if err := wsjson.Write(ctx, c, msg); err != nil {
+					return
+				}
+			}
+		}
+	})
+	defer stopServer()
+
+	logf, stopLog := testLogf(t)
+	defer stopLog()
+	timeout := 200 * time.Millisecond
+	client := weatherflow.NewClient("your_token", &timeout, logf)
+	client.SetURL(url)
+	client.AddDevice(12345)
+
+	var gotMu sync.Mutex
+	got := make(map[int]int)
+	client.Start(func(msg weatherflow.Message) {
+		if m, ok := msg.(*weatherflow.MessageEvtStrike); ok {
+			gotMu.Lock()
+			got[m.Evt.TimeEpoch]++
+			gotMu.Unlock()
+		}
+	})
+	defer client.Stop()
+
+	deadline := time.After(5 * time.Second)
+	for i := 0; i < 3; i++ {
+		select {
+		case <-connections:
+		case <-deadline:
+			t.Fatalf("Timed out waiting for connection %d", i+1)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	client.Stop()
+
+	gotMu.Lock()
+	defer gotMu.Unlock()
+	if len(got) == 0 {
+		t.Fatal("Received no strikes")
+	}
+	for epoch, n := range got {
+		if n != 1 {
+			t.Errorf("Strike at %d delivered %d times, want once", epoch, n)
+		}
+	}
+}
+
+func TestStateChanges(t *testing.T) {
+	url, stopServer := startMockServer()
+	defer stopServer()
+
+	client := weatherflow.NewClient("your_token", nil, t.Logf)
+	client.SetURL(url)
+	client.AddDevice(12345)
+
+	if got := client.State().Conn; got != weatherflow.StateIdle {
+		t.Errorf("State before Start = %v, want %v", got, weatherflow.StateIdle)
+	}
+
+	states := make(chan weatherflow.ConnState, 10)
+	client.OnStateChange(func(s weatherflow.State) {
+		states <- s.Conn
+	})
+
+	msgCh := make(chan weatherflow.Message, 10)
+	client.Start(func(msg weatherflow.Message) {
+		msgCh <- msg
+	})
+
+	want := []weatherflow.ConnState{
+		weatherflow.StateConnecting,
+		weatherflow.StateConnected,
+		weatherflow.StateSubscribed,
+	}
+	for _, w := range want {
+		select {
+		case got := <-states:
+			if got != w {
+				t.Fatalf("State changed to %v, want %v", got, w)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for state %v", w)
+		}
+	}
+
+	var msg weatherflow.Message
+	select {
+	case msg = <-msgCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for message")
+	}
+
+	meta := msg.GetMeta()
+	if meta.ReceivedAt.IsZero() || len(meta.Raw) == 0 {
+		t.Errorf("Message metadata not recorded: %+v", meta)
+	}
+
+	state := client.State()
+	if state.LastMessage.IsZero() {
+		t.Error("State().LastMessage is zero after receiving a message")
+	}
+	if state.Reconnects != 0 {
+		t.Errorf("State().Reconnects = %d, want 0", state.Reconnects)
+	}
+
+	client.Stop()
+	select {
+	case got := <-states:
+		if got != weatherflow.StateStopped {
+			t.Errorf("State changed to %v, want %v", got, weatherflow.StateStopped)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for stopped state")
+	}
+}
+
+func TestAuthErrorStopsClient(t *testing.T) {
+	for _, status := range []int{http.StatusUnauthorized, http.StatusForbidden} {
+		status := status
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			url, stopServer := startMockServerWithHandler(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, http.StatusText(status), status)
+			})
+			defer stopServer()
+
+			client := weatherflow.NewClient("bad_token", nil, t.Logf)
+			client.SetURL(url)
+
+			stopped := make(chan weatherflow.State, 1)
+			client.OnStateChange(func(s weatherflow.State) {
+				if s.Conn == weatherflow.StateStopped {
+					stopped <- s
+				}
+			})
+			client.Start(func(msg weatherflow.Message) {})
+			defer client.Stop()
+
+			select {
+			case s := <-stopped:
+				var authErr *weatherflow.AuthError
+				if !errors.As(s.LastError, &authErr) {
+					t.Fatalf("LastError = %v, want *AuthError", s.LastError)
+				}
+				if authErr.StatusCode != status {
+					t.Errorf("AuthError.StatusCode = %d, want %d", authErr.StatusCode, status)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Timed out waiting for client to stop")
+			}
+		})
+	}
+}
+
+func TestDeviceErrorRemovesDevice(t *testing.T) {
+	url, stopServer := startMockServerWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close(websocket.StatusInternalError, "Internal error")
+
+		if err := wsjson.Write(r.Context(), c, map[string]string{"type": "connection_opened"}); err != nil {
+			return
+		}
+		for {
+			var msg map[string]interface{}
+			if err := wsjson.Read(r.Context(), c, &msg); err != nil {
+				return
+			}
+			if msg["type"] == "listen_start" {
+				_ = wsjson.Write(r.Context(), c, map[string]interface{}{
+					"status": map[string]interface{}{
+						"status_code":    2,
+						"status_message": "NOT AUTHORIZED",
+					},
+					"device_id": msg["device_id"],
+					"type":      "obs_st",
+				})
+			}
+		}
+	})
+	defer stopServer()
+
+	logf, stopLog := testLogf(t)
+	defer stopLog()
+
+	client := weatherflow.NewClient("your_token", nil, logf)
+	client.SetURL(url)
+	client.SetRemoveDeniedDevices(true)
+	client.AddDevice(12345)
+
+	deviceErrs := make(chan *weatherflow.DeviceError, 1)
+	client.OnDeviceError(func(err *weatherflow.DeviceError) {
+		deviceErrs <- err
+	})
+
+	client.Start(func(msg weatherflow.Message) {
+		t.Errorf("Unexpected message: %#v", msg)
+	})
+	defer client.Stop()
+
+	select {
+	case err := <-deviceErrs:
+		if err.DeviceID != 12345 || !err.NotPermitted() {
+			t.Errorf("Got device error %v, want device 12345 not permitted", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for device error")
+	}
+
+	if n := client.DeviceCount(); n != 0 {
+		t.Errorf("DeviceCount() = %d after denied device, want 0", n)
+	}
+}
+
+func TestTokenRedacted(t *testing.T) {
+	const token = "secret-token-1234"
+
+	// Find a port with nothing listening on it.
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := fmt.Sprintf("ws://%s/ws", ln.Addr())
+	ln.Close()
+
+	var mu sync.Mutex
+	var logs []string
+	client := weatherflow.NewClient(token, nil, func(format string, args ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		logs = append(logs, fmt.Sprintf(format, args...))
+	})
+	client.SetURL(url)
+
+	backingOff := make(chan weatherflow.State, 1)
+	client.OnStateChange(func(s weatherflow.State) {
+		if s.Conn == weatherflow.StateBackingOff {
+			backingOff <- s
+		}
+	})
+	client.Start(func(msg weatherflow.Message) {})
+	defer client.Stop()
+
+	select {
+	case s := <-backingOff:
+		if s.LastError == nil {
+			t.Fatal("LastError is nil after failing to connect")
+		}
+		if strings.Contains(s.LastError.Error(), token) {
+			t.Errorf("LastError contains the token: %v", s.LastError)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for connection failure")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, line := range logs {
+		if strings.Contains(line, token) {
+			t.Errorf("Log line contains the token: %s", line)
+		}
+	}
+}
+
+func TestSetToken(t *testing.T) {
+	tokens := make(chan string, 10)
+	url, stopServer := startMockServerWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		tokens <- r.URL.Query().Get("token")
+		mockServerHandler(w, r)
+	})
+	defer stopServer()
+
+	logf, stopLog := testLogf(t)
+	defer stopLog()
+	client := weatherflow.NewClient("old_token", nil, logf)
+	client.SetURL(url)
+	client.AddDevice(12345)
+
+	subscribed := make(chan struct{}, 10)
+	client.OnStateChange(func(s weatherflow.State) {
+		if s.Conn == weatherflow.StateSubscribed {
+			subscribed <- struct{}{}
+		}
+	})
+	client.Start(func(msg weatherflow.Message) {})
+	defer client.Stop()
+
+	for _, want := range []string{"old_token", "new_token"} {
+		select {
+		case got := <-tokens:
+			if got != want {
+				t.Fatalf("Connected with token %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for connection with %q", want)
+		}
+
+		if want == "old_token" {
+			select {
+			case <-subscribed:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Timed out waiting for subscription")
+			}
+			client.SetToken("new_token")
+		}
+	}
+
+	// The token was rotated without a reconnect.
+	if n := client.State().Reconnects; n != 0 {
+		t.Errorf("Reconnects = %d after SetToken, want 0", n)
+	}
+}
+
+func TestSetRejectedToken(t *testing.T) {
+	url, stopServer := startMockServerWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("token") == "bad_token" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mockServerHandler(w, r)
+	})
+	defer stopServer()
+
+	logf, stopLog := testLogf(t)
+	defer stopLog()
+	client := weatherflow.NewClient("good_token", nil, logf)
+	client.SetURL(url)
+	client.AddDevice(12345)
+
+	subscribed := make(chan struct{}, 10)
+	backingOff := make(chan weatherflow.State, 10)
+	client.OnStateChange(func(s weatherflow.State) {
+		switch s.Conn {
+		case weatherflow.StateSubscribed:
+			subscribed <- struct{}{}
+		case weatherflow.StateBackingOff:
+			backingOff <- s
+		}
+	})
+	client.Start(func(msg weatherflow.Message) {})
+	defer client.Stop()
+
+	select {
+	case <-subscribed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for subscription")
+	}
+	client.SetToken("bad_token")
+
+	// The replacement connection's rejection is recorded before the Client
+	// backs off and tries again.
+	select {
+	case s := <-backingOff:
+		var authErr *weatherflow.AuthError
+		if !errors.As(s.LastError, &authErr) {
+			t.Errorf("LastError = %v after the rotation failed, want *AuthError", s.LastError)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the Client to back off")
+	}
+}
@@ -0,0 +1,188 @@
+package weatherflow
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WindStats summarises the wind over a window of rapid_wind observations.
+// Speeds are in m/s and directions in degrees. Calm observations (zero
+// speed) count towards the speeds but not the directions, since the sensor
+// reports no direction for them.
+type WindStats struct {
+	Start   time.Time // time of the first observation in the window
+	End     time.Time // time of the last observation in the window
+	Samples int
+
+	ScalarSpeed     float64 // mean of the speeds
+	ScalarDirection float64 // direction of the mean unit vector, ignoring speed
+	VectorSpeed     float64 // magnitude of the mean wind vector
+	VectorDirection float64 // direction of the mean wind vector
+
+	Gust     float64 // highest speed
+	GustTime time.Time
+	Lull     float64 // lowest speed
+
+	// DirectionFrom and DirectionTo are the ends of the smallest clockwise
+	// arc containing every direction in the window, such as 350 to 20.
+	DirectionFrom int
+	DirectionTo   int
+	// DirectionStdDev is the standard deviation of the direction, by
+	// Yamartino's method.
+	DirectionStdDev float64
+}
+
+// DirectionRange returns the width of the arc from DirectionFrom to
+// DirectionTo, in degrees.
+func (s WindStats) DirectionRange() int {
+	return ((s.DirectionTo-s.DirectionFrom)%360 + 360) % 360
+}
+
+// WindAggregator keeps recent rapid_wind observations for each device and
+// summarises them over rolling windows, such as the 2- and 10-minute
+// averages used in aviation reports. It is safe for concurrent use.
+type WindAggregator struct {
+	maxWindow time.Duration
+	history   map[int][]RapidWindData
+	mu        sync.Mutex
+}
+
+// NewWindAggregator creates a WindAggregator that keeps enough history for
+// windows up to maxWindow long.
+func NewWindAggregator(maxWindow time.Duration) *WindAggregator {
+	return &WindAggregator{
+		maxWindow: maxWindow,
+		history:   make(map[int][]RapidWindData),
+	}
+}
+
+// Handle records a rapid_wind message. Other messages are ignored.
+func (a *WindAggregator) Handle(msg Message) {
+	if m, ok := msg.(*MessageRapidWind); ok {
+		a.Add(m.DeviceID, m.Ob)
+	}
+}
+
+// Add records a rapid_wind observation for a device.
+func (a *WindAggregator) Add(deviceID int, rw RapidWindData) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	h := a.history[deviceID]
+
+	i := sort.Search(len(h), func(i int) bool { return h[i].TimeEpoch >= rw.TimeEpoch })
+	if i < len(h) && h[i].TimeEpoch == rw.TimeEpoch {
+		return
+	}
+	h = append(h, RapidWindData{})
+	copy(h[i+1:], h[i:])
+	h[i] = rw
+
+	cutoff := h[len(h)-1].Time().Add(-a.maxWindow)
+	drop := 0
+	for drop < len(h) && !h[drop].Time().After(cutoff) {
+		drop++
+	}
+	a.history[deviceID] = h[drop:]
+}
+
+// Stats summarises a device's observations over the window ending at its
+// latest observation. window is capped at the aggregator's maxWindow. ok is
+// false if there are no observations.
+func (a *WindAggregator) Stats(deviceID int, window time.Duration) (stats WindStats, ok bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	h := a.history[deviceID]
+	if len(h) == 0 {
+		return WindStats{}, false
+	}
+
+	cutoff := h[len(h)-1].Time().Add(-window)
+	i := sort.Search(len(h), func(i int) bool { return h[i].Time().After(cutoff) })
+	return windStats(h[i:]), true
+}
+
+// windStats summarises a non-empty, ordered set of observations.
+func windStats(obs []RapidWindData) WindStats {
+	s := WindStats{
+		Start:   obs[0].Time(),
+		End:     obs[len(obs)-1].Time(),
+		Samples: len(obs),
+		Lull:    math.Inf(1),
+	}
+
+	var sumSpeed, sumU, sumV, sumSin, sumCos float64
+	var dirs []int
+	for _, rw := range obs {
+		sumSpeed += rw.WindSpeed
+		if rw.WindSpeed > s.Gust || s.GustTime.IsZero() {
+			s.Gust, s.GustTime = rw.WindSpeed, rw.Time()
+		}
+		s.Lull = math.Min(s.Lull, rw.WindSpeed)
+
+		if rw.WindSpeed <= 0 {
+			continue
+		}
+		rad := float64(rw.WindDirection) * math.Pi / 180
+		sin, cos := math.Sincos(rad)
+		sumSin += sin
+		sumCos += cos
+		sumU += rw.WindSpeed * sin
+		sumV += rw.WindSpeed * cos
+		dirs = append(dirs, rw.WindDirection%360)
+	}
+
+	n := float64(len(obs))
+	s.ScalarSpeed = sumSpeed / n
+	s.VectorSpeed = math.Hypot(sumU, sumV) / n
+	s.VectorDirection = compassDegrees(sumU, sumV)
+
+	if len(dirs) == 0 {
+		return s
+	}
+
+	s.ScalarDirection = compassDegrees(sumSin, sumCos)
+	s.DirectionFrom, s.DirectionTo = directionArc(dirs)
+
+	// Yamartino (1984).
+	nd := float64(len(dirs))
+	sa, ca := sumSin/nd, sumCos/nd
+	eps := math.Sqrt(math.Max(0, 1-(sa*sa+ca*ca)))
+	s.DirectionStdDev = math.Asin(eps) * (1 + (2/math.Sqrt(3)-1)*eps*eps*eps) * 180 / math.Pi
+
+	return s
+}
+
+// compassDegrees returns the direction of the vector (east, north) in
+// degrees clockwise from north, in [0, 360).
+func compassDegrees(east, north float64) float64 {
+	if math.Hypot(east, north) < 1e-9 {
+		return 0 // no prevailing direction
+	}
+	deg := math.Atan2(east, north) * 180 / math.Pi
+	if deg < 0 {
+		deg += 360
+	}
+	return math.Mod(deg, 360)
+}
+
+// directionArc returns the ends of the smallest clockwise arc containing all
+// of dirs: the arc opposite the largest gap between neighbouring directions.
+func directionArc(dirs []int) (from, to int) {
+	sort.Ints(dirs)
+
+	// The gap from the last direction round to the first.
+	gap := dirs[0] + 360 - dirs[len(dirs)-1]
+	from, to = dirs[0], dirs[len(dirs)-1]
+
+	for i := 1; i < len(dirs); i++ {
+		if g := dirs[i] - dirs[i-1]; g > gap {
+			gap = g
+			from, to = dirs[i], dirs[i-1]
+		}
+	}
+	return from, to
+}
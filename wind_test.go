@@ -0,0 +1,147 @@
+package weatherflow_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/tris/weatherflow"
+)
+
+func TestWindAggregator(t *testing.T) {
+	const start = 1681700000
+
+	tests := []struct {
+		name       string
+		speeds     []float64
+		directions []int
+		want       weatherflow.WindStats
+		wantRange  int
+	}{
+		{
+			name:       "steady",
+			speeds:     []float64{5, 5, 5},
+			directions: []int{90, 90, 90},
+			want: weatherflow.WindStats{
+				Samples: 3, ScalarSpeed: 5, ScalarDirection: 90, VectorSpeed: 5, VectorDirection: 90,
+				Gust: 5, Lull: 5, DirectionFrom: 90, DirectionTo: 90,
+			},
+		},
+		{
+			name:       "wraps around north",
+			speeds:     []float64{4, 6, 8},
+			directions: []int{350, 0, 10},
+			want: weatherflow.WindStats{
+				Samples: 3, ScalarSpeed: 6, ScalarDirection: 0, VectorSpeed: 5.939, VectorDirection: 2.232,
+				Gust: 8, Lull: 4, DirectionFrom: 350, DirectionTo: 10, DirectionStdDev: 8.17,
+			},
+			wantRange: 20,
+		},
+		{
+			name:       "opposing",
+			speeds:     []float64{5, 5},
+			directions: []int{90, 270},
+			want: weatherflow.WindStats{
+				Samples: 2, ScalarSpeed: 5, ScalarDirection: 0, VectorSpeed: 0, VectorDirection: 0,
+				Gust: 5, Lull: 5, DirectionFrom: 90, DirectionTo: 270, DirectionStdDev: 103.923,
+			},
+			wantRange: 180,
+		},
+		{
+			name:       "calm samples have no direction",
+			speeds:     []float64{0, 3, 0},
+			directions: []int{0, 200, 0},
+			want: weatherflow.WindStats{
+				Samples: 3, ScalarSpeed: 1, ScalarDirection: 200, VectorSpeed: 1, VectorDirection: 200,
+				Gust: 3, Lull: 0, DirectionFrom: 200, DirectionTo: 200,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		agg := weatherflow.NewWindAggregator(10 * time.Minute)
+		for i := range test.speeds {
+			agg.Add(1, weatherflow.RapidWindData{TimeEpoch: start + i*3, WindSpeed: test.speeds[i], WindDirection: test.directions[i]})
+		}
+
+		got, ok := agg.Stats(1, 2*time.Minute)
+		if !ok {
+			t.Errorf("%s: Stats: expected ok = true", test.name)
+			continue
+		}
+
+		want := test.want
+		checks := []struct {
+			field     string
+			got, want float64
+		}{
+			{"Samples", float64(got.Samples), float64(want.Samples)},
+			{"ScalarSpeed", got.ScalarSpeed, want.ScalarSpeed},
+			{"ScalarDirection", got.ScalarDirection, want.ScalarDirection},
+			{"VectorSpeed", got.VectorSpeed, want.VectorSpeed},
+			{"VectorDirection", got.VectorDirection, want.VectorDirection},
+			{"Gust", got.Gust, want.Gust},
+			{"Lull", got.Lull, want.Lull},
+			{"DirectionFrom", float64(got.DirectionFrom), float64(want.DirectionFrom)},
+			{"DirectionTo", float64(got.DirectionTo), float64(want.DirectionTo)},
+			{"DirectionStdDev", got.DirectionStdDev, want.DirectionStdDev},
+			{"DirectionRange", float64(got.DirectionRange()), float64(test.wantRange)},
+		}
+		for _, c := range checks {
+			// Directions of 0 and 360 are the same.
+			diff := math.Abs(c.got - c.want)
+			if diff > 0.01 && math.Abs(diff-360) > 0.01 {
+				t.Errorf("%s: %s = %.3f, want %.3f", test.name, c.field, c.got, c.want)
+			}
+		}
+	}
+}
+
+func TestWindAggregatorWindows(t *testing.T) {
+	const start = 1681700000
+	agg := weatherflow.NewWindAggregator(10 * time.Minute)
+
+	if _, ok := agg.Stats(1, 2*time.Minute); ok {
+		t.Error("Stats with no observations: expected ok = false")
+	}
+
+	// Fifteen minutes of observations every 3 seconds, with a gust of 12 m/s
+	// eight minutes before the end, and one observation arriving late.
+	for i := 0; i < 300; i++ {
+		if i == 100 {
+			continue
+		}
+		speed := 4.0
+		if i == 140 {
+			speed = 12
+		}
+		agg.Handle(&weatherflow.MessageRapidWind{DeviceID: 1, Ob: weatherflow.RapidWindData{TimeEpoch: start + i*3, WindSpeed: speed, WindDirection: 180}})
+	}
+	agg.Add(1, weatherflow.RapidWindData{TimeEpoch: start + 100*3, WindSpeed: 4, WindDirection: 180})
+
+	tests := []struct {
+		window      time.Duration
+		wantSamples int
+		wantGust    float64
+		wantGustAt  time.Time
+	}{
+		{2 * time.Minute, 40, 4, time.Unix(start+260*3, 0)},
+		{10 * time.Minute, 200, 12, time.Unix(start+140*3, 0)},
+		{time.Hour, 200, 12, time.Unix(start+140*3, 0)}, // capped at the maximum window
+	}
+
+	for _, test := range tests {
+		got, ok := agg.Stats(1, test.window)
+		if !ok {
+			t.Errorf("%v: Stats: expected ok = true", test.window)
+			continue
+		}
+		if got.Samples != test.wantSamples || got.Gust != test.wantGust || !got.GustTime.Equal(test.wantGustAt) {
+			t.Errorf("%v: got %d samples, gust %v at %v; want %d samples, gust %v at %v",
+				test.window, got.Samples, got.Gust, got.GustTime, test.wantSamples, test.wantGust, test.wantGustAt)
+		}
+		if !got.End.Equal(time.Unix(start+299*3, 0)) {
+			t.Errorf("%v: End = %v, want %v", test.window, got.End, time.Unix(start+299*3, 0))
+		}
+	}
+}
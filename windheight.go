@@ -0,0 +1,135 @@
+package weatherflow
+
+import "math"
+
+// StandardWindHeight is the height, in metres, at which synoptic stations
+// measure wind.
+const StandardWindHeight = 10.0
+
+// Roughness lengths, in metres, for the Davenport terrain classes.
+const (
+	RoughnessSea         = 0.0002 // open sea or lake
+	RoughnessSmooth      = 0.005  // mud flats, snow; no vegetation or obstacles
+	RoughnessOpen        = 0.03   // open flat terrain, grass, few isolated obstacles
+	RoughnessRoughlyOpen = 0.1    // low crops, occasional large obstacles
+	RoughnessRough       = 0.25   // high crops, scattered obstacles
+	RoughnessVeryRough   = 0.5    // parkland, bushes, numerous obstacles
+	RoughnessClosed      = 1.0    // regular large obstacle coverage: suburb, forest
+)
+
+const defaultPowerExponent = 1.0 / 7
+
+// WindProfile is a model of how wind speed varies with height.
+type WindProfile int
+
+const (
+	// LogProfile is the logarithmic wind profile of a neutral surface
+	// layer, determined by the terrain's roughness length.
+	LogProfile WindProfile = iota
+	// PowerProfile is the empirical power law, determined by its exponent.
+	PowerProfile
+)
+
+// HeightCorrection scales wind speeds measured at one height to what they
+// would be at another, normally the standard 10 m, so that stations mounted
+// at different heights can be compared.
+type HeightCorrection struct {
+	Profile WindProfile
+
+	// SensorHeight is the anemometer's height above ground, in metres. If it
+	// is zero, speeds are left unchanged.
+	SensorHeight float64
+
+	// TargetHeight is the height to correct to, in metres. The default is
+	// StandardWindHeight.
+	TargetHeight float64
+
+	// Roughness is the roughness length for LogProfile, in metres. The
+	// default is RoughnessOpen.
+	Roughness float64
+
+	// Exponent is the exponent for PowerProfile. The default is 1/7, the
+	// usual value for open terrain.
+	Exponent float64
+}
+
+// ForDevice returns h with the sensor height recorded for a device, if
+// there is one.
+func (h HeightCorrection) ForDevice(info DeviceInfo) HeightCorrection {
+	if info.Height > 0 {
+		h.SensorHeight = info.Height
+	}
+	return h
+}
+
+// Factor returns the ratio of the wind speed at the target height to that
+// at the sensor.
+func (h HeightCorrection) Factor() float64 {
+	target := h.TargetHeight
+	if target == 0 {
+		target = StandardWindHeight
+	}
+	if h.SensorHeight <= 0 || h.SensorHeight == target {
+		return 1
+	}
+
+	switch h.Profile {
+	case PowerProfile:
+		exp := h.Exponent
+		if exp == 0 {
+			exp = defaultPowerExponent
+		}
+		return math.Pow(target/h.SensorHeight, exp)
+
+	default:
+		z0 := h.Roughness
+		if z0 == 0 {
+			z0 = RoughnessOpen
+		}
+		if h.SensorHeight <= z0 || target <= z0 {
+			// The profile isn't defined within the roughness layer.
+			return 1
+		}
+		return math.Log(target/z0) / math.Log(h.SensorHeight/z0)
+	}
+}
+
+// Speed corrects a single wind speed.
+func (h HeightCorrection) Speed(v float64) float64 {
+	return v * h.Factor()
+}
+
+// ObsSt returns obs with its wind lull, average and gust corrected.
+func (h HeightCorrection) ObsSt(obs ObsStData) ObsStData {
+	f := h.Factor()
+	obs.WindLull *= f
+	obs.WindAvg *= f
+	obs.WindGust *= f
+	return obs
+}
+
+// RapidWind returns rw with its wind speed corrected.
+func (h HeightCorrection) RapidWind(rw RapidWindData) RapidWindData {
+	rw.WindSpeed *= h.Factor()
+	return rw
+}
+
+// Message returns a copy of an obs_st or rapid_wind message with its wind
+// speeds corrected. Other messages are returned unchanged.
+func (h HeightCorrection) Message(msg Message) Message {
+	switch m := msg.(type) {
+	case *MessageObsSt:
+		c := *m
+		c.Obs = make([]ObsStData, len(m.Obs))
+		for i, obs := range m.Obs {
+			c.Obs[i] = h.ObsSt(obs)
+		}
+		return &c
+
+	case *MessageRapidWind:
+		c := *m
+		c.Ob = h.RapidWind(m.Ob)
+		return &c
+	}
+	return msg
+}
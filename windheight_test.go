@@ -0,0 +1,63 @@
+package weatherflow_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tris/weatherflow"
+)
+
+func TestHeightCorrectionFactor(t *testing.T) {
+	tests := []struct {
+		name string
+		hc   weatherflow.HeightCorrection
+		want float64
+	}{
+		{name: "log, default roughness", hc: weatherflow.HeightCorrection{SensorHeight: 2.5}, want: 1.3134},
+		{name: "log, very rough", hc: weatherflow.HeightCorrection{SensorHeight: 2, Roughness: weatherflow.RoughnessVeryRough}, want: 2.1610},
+		{name: "power, default exponent", hc: weatherflow.HeightCorrection{Profile: weatherflow.PowerProfile, SensorHeight: 2.5}, want: 1.2190},
+		{name: "power, custom exponent", hc: weatherflow.HeightCorrection{Profile: weatherflow.PowerProfile, SensorHeight: 2.5, Exponent: 0.5}, want: 2},
+		{name: "above target", hc: weatherflow.HeightCorrection{Profile: weatherflow.PowerProfile, SensorHeight: 40}, want: 0.8204},
+		{name: "custom target", hc: weatherflow.HeightCorrection{SensorHeight: 10, TargetHeight: 10}, want: 1},
+		{name: "unknown height", hc: weatherflow.HeightCorrection{}, want: 1},
+		{name: "within roughness layer", hc: weatherflow.HeightCorrection{SensorHeight: 0.5, Roughness: weatherflow.RoughnessClosed}, want: 1},
+	}
+
+	for _, test := range tests {
+		if got := test.hc.Factor(); math.Abs(got-test.want) > 0.0001 {
+			t.Errorf("%s: Factor() = %.4f, want %.4f", test.name, got, test.want)
+		}
+	}
+}
+
+func TestHeightCorrectionMessages(t *testing.T) {
+	hc := weatherflow.HeightCorrection{Profile: weatherflow.PowerProfile, Exponent: 0.5}.
+		ForDevice(weatherflow.DeviceInfo{Height: 2.5})
+
+	obs := &weatherflow.MessageObsSt{
+		DeviceID: 1,
+		Obs:      []weatherflow.ObsStData{{WindLull: 1, WindAvg: 2, WindGust: 3, WindDirection: 90}},
+	}
+	got := hc.Message(obs).(*weatherflow.MessageObsSt)
+	if ob := got.Obs[0]; ob.WindLull != 2 || ob.WindAvg != 4 || ob.WindGust != 6 || ob.WindDirection != 90 {
+		t.Errorf("corrected obs_st = %+v", ob)
+	}
+	if obs.Obs[0].WindAvg != 2 {
+		t.Error("Message modified the original obs_st")
+	}
+
+	rw := &weatherflow.MessageRapidWind{DeviceID: 1, Ob: weatherflow.RapidWindData{WindSpeed: 1.5}}
+	if got := hc.Message(rw).(*weatherflow.MessageRapidWind); got.Ob.WindSpeed != 3 || rw.Ob.WindSpeed != 1.5 {
+		t.Errorf("corrected rapid_wind speed = %v (original %v), want 3 (1.5)", got.Ob.WindSpeed, rw.Ob.WindSpeed)
+	}
+
+	ack := &weatherflow.MessageAck{}
+	if got := hc.Message(ack); got != ack {
+		t.Error("Message changed an ack")
+	}
+
+	// A device with no recorded height keeps the configured one.
+	if got := hc.ForDevice(weatherflow.DeviceInfo{}).SensorHeight; got != 2.5 {
+		t.Errorf("ForDevice with no height: SensorHeight = %v, want 2.5", got)
+	}
+}
@@ -0,0 +1,428 @@
+package weatherflow
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"sync"
+)
+
+var (
+	// DefaultSpeedBins are the lower edges, in m/s, of the speed bins used
+	// when WindRoseConfig.SpeedBins is empty.
+	DefaultSpeedBins = []float64{0, 2, 4, 6, 8, 10}
+
+	defaultCalmThreshold = 0.5 // m/s
+	defaultSectors       = 16
+)
+
+// windRoseColors are the fill colours of successive speed bins in SVG output.
+var windRoseColors = []string{"#c6dbef", "#9ecae1", "#6baed6", "#4292c6", "#2171b5", "#08519c", "#08306b"}
+
+// WindRoseConfig configures a WindRose. Zero values select the defaults.
+type WindRoseConfig struct {
+	// Sectors is the number of direction sectors, centred on north and
+	// spaced evenly. The default is 16.
+	Sectors int
+
+	// SpeedBins are the ascending lower edges of the speed bins, in m/s.
+	// The last bin is open-ended. The default is DefaultSpeedBins.
+	SpeedBins []float64
+
+	// CalmThreshold is the speed, in m/s, below which the wind is counted as
+	// calm rather than in a sector. The default is 0.5 m/s; a negative value
+	// disables the calm count, so that every observation goes in a sector.
+	CalmThreshold float64
+}
+
+// WindRose accumulates the joint distribution of wind direction and speed.
+// It is safe for concurrent use.
+type WindRose struct {
+	sectors       int
+	speedBins     []float64
+	calmThreshold float64
+	counts        [][]int // [sector][speed bin]
+	calm          int
+	mu            sync.Mutex
+}
+
+// NewWindRose creates an empty WindRose. Zero values in cfg select the
+// defaults: 16 sectors, DefaultSpeedBins, and calms below 0.5 m/s.
+func NewWindRose(cfg WindRoseConfig) *WindRose {
+	if cfg.Sectors <= 0 {
+		cfg.Sectors = defaultSectors
+	}
+	if len(cfg.SpeedBins) == 0 {
+		cfg.SpeedBins = DefaultSpeedBins
+	}
+	switch {
+	case cfg.CalmThreshold == 0:
+		cfg.CalmThreshold = defaultCalmThreshold
+	case cfg.CalmThreshold < 0:
+		cfg.CalmThreshold = 0 // no speed is below it
+	}
+
+	r := &WindRose{
+		sectors:       cfg.Sectors,
+		speedBins:     append([]float64(nil), cfg.SpeedBins...),
+		calmThreshold: cfg.CalmThreshold,
+	}
+	r.counts = r.newCounts()
+	return r
+}
+
+func (r *WindRose) newCounts() [][]int {
+	counts := make([][]int, r.sectors)
+	for i := range counts {
+		counts[i] = make([]int, len(r.speedBins))
+	}
+	return counts
+}
+
+// Handle records the wind in a rapid_wind or obs_st message. Other messages
+// are ignored. Feed a rose one of the two streams per device, not both, or
+// the same wind will be counted twice.
+func (r *WindRose) Handle(msg Message) {
+	switch m := msg.(type) {
+	case *MessageRapidWind:
+		r.Add(m.Ob.WindSpeed, m.Ob.WindDirection)
+	case *MessageObsSt:
+		for _, obs := range m.Obs {
+			r.Add(obs.WindAvg, obs.WindDirection)
+		}
+	}
+}
+
+// Add records one observation of the wind's speed, in m/s, and direction, in
+// degrees.
+func (r *WindRose) Add(speed float64, direction int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if speed < r.calmThreshold {
+		r.calm++
+		return
+	}
+	r.counts[r.sector(direction)][r.bin(speed)]++
+}
+
+// sector returns the index of the sector containing direction.
+func (r *WindRose) sector(direction int) int {
+	width := 360 / float64(r.sectors)
+	d := math.Mod(float64(direction), 360)
+	if d < 0 {
+		d += 360
+	}
+	return int(math.Floor((d+width/2)/width)) % r.sectors
+}
+
+// bin returns the index of the speed bin containing speed.
+func (r *WindRose) bin(speed float64) int {
+	for i := len(r.speedBins) - 1; i > 0; i-- {
+		if speed >= r.speedBins[i] {
+			return i
+		}
+	}
+	return 0
+}
+
+// Merge adds the observations recorded by other, which must have the same
+// configuration, to r.
+func (r *WindRose) Merge(other *WindRose) error {
+	if r == other {
+		return errors.New("can't merge a wind rose with itself")
+	}
+
+	// Copy other first rather than holding both locks, which could deadlock
+	// against a merge in the opposite direction.
+	other.mu.Lock()
+	cfg := WindRoseConfig{Sectors: other.sectors, SpeedBins: other.speedBins, CalmThreshold: other.calmThreshold}
+	counts, calm := other.copyCounts(), other.calm
+	other.mu.Unlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.sameConfig(cfg) {
+		return errors.New("can't merge wind roses with different sectors, speed bins or calm threshold")
+	}
+
+	for i := range r.counts {
+		for j := range r.counts[i] {
+			r.counts[i][j] += counts[i][j]
+		}
+	}
+	r.calm += calm
+	return nil
+}
+
+func (r *WindRose) sameConfig(cfg WindRoseConfig) bool {
+	if r.sectors != cfg.Sectors || r.calmThreshold != cfg.CalmThreshold || len(r.speedBins) != len(cfg.SpeedBins) {
+		return false
+	}
+	for i := range r.speedBins {
+		if r.speedBins[i] != cfg.SpeedBins[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Total returns the number of observations recorded, including calms.
+func (r *WindRose) Total() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.total()
+}
+
+func (r *WindRose) total() int {
+	n := r.calm
+	for _, sector := range r.counts {
+		for _, c := range sector {
+			n += c
+		}
+	}
+	return n
+}
+
+// Counts returns the number of observations in each sector and speed bin,
+// indexed [sector][bin], and the number of calms. Sector 0 is centred on
+// north.
+func (r *WindRose) Counts() (counts [][]int, calm int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.copyCounts(), r.calm
+}
+
+func (r *WindRose) copyCounts() [][]int {
+	counts := r.newCounts()
+	for i := range r.counts {
+		copy(counts[i], r.counts[i])
+	}
+	return counts
+}
+
+// SectorLabel returns the name of a sector: a compass point if the rose has
+// 4, 8 or 16 sectors, otherwise its centre in degrees.
+func (r *WindRose) SectorLabel(sector int) string {
+	points := []string{"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE", "S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW"}
+	switch r.sectors {
+	case 4, 8, 16:
+		return points[sector*16/r.sectors]
+	}
+	return strconv.FormatFloat(float64(sector)*360/float64(r.sectors), 'f', -1, 64)
+}
+
+// BinLabel returns the range of a speed bin, such as "2-4" or "10+".
+func (r *WindRose) BinLabel(bin int) string {
+	lo := r.speedBins[bin]
+	if bin == 0 {
+		lo = math.Max(lo, r.calmThreshold)
+	}
+	if bin == len(r.speedBins)-1 {
+		return formatSpeed(lo) + "+"
+	}
+	return formatSpeed(lo) + "-" + formatSpeed(r.speedBins[bin+1])
+}
+
+func formatSpeed(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// WriteCSV writes the rose as a table of percentages of all observations,
+// with a row per sector, a column per speed bin and a final row for calms.
+func (r *WindRose) WriteCSV(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cw := csv.NewWriter(w)
+	total := float64(r.total())
+	percent := func(n int) string {
+		if total == 0 {
+			return "0.00"
+		}
+		return strconv.FormatFloat(100*float64(n)/total, 'f', 2, 64)
+	}
+
+	header := []string{"direction"}
+	for j := range r.speedBins {
+		header = append(header, r.BinLabel(j))
+	}
+	header = append(header, "total")
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for i, sector := range r.counts {
+		row := []string{r.SectorLabel(i)}
+		sum := 0
+		for _, c := range sector {
+			row = append(row, percent(c))
+			sum += c
+		}
+		row = append(row, percent(sum))
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	calm := make([]string, len(header))
+	calm[0] = "calm"
+	for j := 1; j < len(calm); j++ {
+		calm[j] = percent(0)
+	}
+	calm[len(calm)-1] = percent(r.calm)
+	if err := cw.Write(calm); err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// windRoseJSON is the serialised form of a WindRose.
+type windRoseJSON struct {
+	Sectors       int       `json:"sectors"`
+	SpeedBins     []float64 `json:"speed_bins"`
+	CalmThreshold float64   `json:"calm_threshold"`
+	Calm          int       `json:"calm"`
+	Counts        [][]int   `json:"counts"`
+}
+
+// MarshalJSON encodes the rose's configuration and counts, so that it can be
+// saved and later restored with UnmarshalJSON.
+func (r *WindRose) MarshalJSON() ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return json.Marshal(windRoseJSON{
+		Sectors:       r.sectors,
+		SpeedBins:     r.speedBins,
+		CalmThreshold: r.calmThreshold,
+		Calm:          r.calm,
+		Counts:        r.counts,
+	})
+}
+
+func (r *WindRose) UnmarshalJSON(data []byte) error {
+	var v windRoseJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Sectors <= 0 || len(v.SpeedBins) == 0 || len(v.Counts) != v.Sectors {
+		return errors.New("invalid wind rose")
+	}
+	for _, sector := range v.Counts {
+		if len(sector) != len(v.SpeedBins) {
+			return errors.New("invalid wind rose")
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sectors = v.Sectors
+	r.speedBins = v.SpeedBins
+	r.calmThreshold = v.CalmThreshold
+	r.calm = v.Calm
+	r.counts = v.Counts
+	return nil
+}
+
+// WriteSVG renders the rose as an SVG image size pixels square. Each
+// sector's wedge is stacked by speed bin, with its length proportional to
+// the sector's share of the observations.
+func (r *WindRose) WriteSVG(w io.Writer, size int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	const margin = 30
+	center := float64(size) / 2
+	radius := center - margin
+	total := r.total()
+
+	// Scale so the busiest sector reaches the edge.
+	busiest := 0
+	for _, sector := range r.counts {
+		sum := 0
+		for _, c := range sector {
+			sum += c
+		}
+		if sum > busiest {
+			busiest = sum
+		}
+	}
+
+	point := func(deg, rad float64) (float64, float64) {
+		sin, cos := math.Sincos(deg * math.Pi / 180)
+		return center + rad*sin, center - rad*cos
+	}
+
+	bw := &errWriter{w: w}
+	bw.printf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n", size, size, size, size)
+
+	// Grid rings at quarters of the busiest sector's share.
+	for i := 1; i <= 4; i++ {
+		bw.printf(`<circle cx="%.1f" cy="%.1f" r="%.1f" fill="none" stroke="#ccc"/>`+"\n", center, center, radius*float64(i)/4)
+	}
+
+	width := 360 / float64(r.sectors)
+	for i, sector := range r.counts {
+		gap := width / 20 // between neighbouring wedges
+		from, to := float64(i)*width-width/2+gap, float64(i)*width+width/2-gap
+		inner, cum := 0.0, 0
+		for j, c := range sector {
+			if c == 0 {
+				continue
+			}
+			cum += c
+			outer := radius * float64(cum) / float64(busiest)
+
+			x1, y1 := point(from, outer)
+			x2, y2 := point(to, outer)
+			x3, y3 := point(to, inner)
+			x4, y4 := point(from, inner)
+			bw.printf(`<path d="M%.1f,%.1f A%.1f,%.1f 0 0,1 %.1f,%.1f L%.1f,%.1f A%.1f,%.1f 0 0,0 %.1f,%.1f Z" fill="%s"><title>%s %s m/s: %d</title></path>`+"\n",
+				x1, y1, outer, outer, x2, y2, x3, y3, inner, inner, x4, y4,
+				windRoseColors[j%len(windRoseColors)], r.SectorLabel(i), r.BinLabel(j), c)
+			inner = outer
+		}
+	}
+
+	for _, p := range []struct {
+		label string
+		deg   float64
+	}{{"N", 0}, {"E", 90}, {"S", 180}, {"W", 270}} {
+		x, y := point(p.deg, radius+margin/2)
+		bw.printf(`<text x="%.1f" y="%.1f" text-anchor="middle" dominant-baseline="middle">%s</text>`+"\n", x, y, p.label)
+	}
+
+	calm := 0.0
+	if total > 0 {
+		calm = 100 * float64(r.calm) / float64(total)
+	}
+	bw.printf(`<text x="4" y="%d">calm %.1f%%</text>`+"\n", size-6, calm)
+	for j := range r.speedBins {
+		y := 4 + j*16
+		bw.printf(`<rect x="%d" y="%d" width="12" height="12" fill="%s"/><text x="%d" y="%d">%s m/s</text>`+"\n",
+			4, y, windRoseColors[j%len(windRoseColors)], 20, y+10, r.BinLabel(j))
+	}
+
+	bw.printf("</svg>\n")
+	return bw.err
+}
+
+// errWriter writes formatted output until the first error, which it keeps.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) printf(format string, args ...interface{}) {
+	if e.err == nil {
+		_, e.err = fmt.Fprintf(e.w, format, args...)
+	}
+}
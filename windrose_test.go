@@ -0,0 +1,150 @@
+package weatherflow_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tris/weatherflow"
+)
+
+func TestWindRose(t *testing.T) {
+	rose := weatherflow.NewWindRose(weatherflow.WindRoseConfig{})
+
+	rose.Add(3, 355)                                                                                            // N, 2-4
+	rose.Add(3, 11)                                                                                             // N, 2-4
+	rose.Add(1, 12)                                                                                             // NNE, 0.5-2
+	rose.Add(12, 180)                                                                                           // S, 10+
+	rose.Add(0.2, 90)                                                                                           // calm
+	rose.Handle(&weatherflow.MessageRapidWind{Ob: weatherflow.RapidWindData{WindSpeed: 5, WindDirection: 270}}) // W, 4-6
+	rose.Handle(&weatherflow.MessageObsSt{Obs: []weatherflow.ObsStData{{WindAvg: 7, WindDirection: 90}}})       // E, 6-8
+	rose.Handle(&weatherflow.MessageAck{})
+
+	counts, calm := rose.Counts()
+	want := map[[2]int]int{
+		{0, 1}:  2,
+		{1, 0}:  1,
+		{8, 5}:  1,
+		{12, 2}: 1,
+		{4, 3}:  1,
+	}
+	for i := range counts {
+		for j, c := range counts[i] {
+			if c != want[[2]int{i, j}] {
+				t.Errorf("counts[%s][%s] = %d, want %d", rose.SectorLabel(i), rose.BinLabel(j), c, want[[2]int{i, j}])
+			}
+		}
+	}
+	if calm != 1 || rose.Total() != 7 {
+		t.Errorf("got %d calm of %d total, want 1 of 7", calm, rose.Total())
+	}
+
+	labels := []string{rose.SectorLabel(0), rose.SectorLabel(1), rose.SectorLabel(15), rose.BinLabel(0), rose.BinLabel(1), rose.BinLabel(5)}
+	if diff := cmp.Diff([]string{"N", "NNE", "NNW", "0.5-2", "2-4", "10+"}, labels); diff != "" {
+		t.Errorf("labels mismatch (-want +got):\n%s", diff)
+	}
+
+	// A negative calm threshold puts even still air in a sector.
+	noCalm := weatherflow.NewWindRose(weatherflow.WindRoseConfig{CalmThreshold: -1})
+	noCalm.Add(0.2, 90)
+	noCalm.Add(0, 0)
+	if counts, calm := noCalm.Counts(); calm != 0 || counts[4][0] != 1 || counts[0][0] != 1 || noCalm.BinLabel(0) != "0-2" {
+		t.Errorf("without calms: got %d calm, E %d, N %d, bin 0 %q; want 0, 1, 1, \"0-2\"", calm, counts[4][0], counts[0][0], noCalm.BinLabel(0))
+	}
+
+	odd := weatherflow.NewWindRose(weatherflow.WindRoseConfig{Sectors: 36})
+	if got := odd.SectorLabel(9); got != "90" {
+		t.Errorf("SectorLabel(9) with 36 sectors = %q, want \"90\"", got)
+	}
+}
+
+func TestWindRoseMerge(t *testing.T) {
+	cfg := weatherflow.WindRoseConfig{Sectors: 4, SpeedBins: []float64{0, 5}}
+	a := weatherflow.NewWindRose(cfg)
+	b := weatherflow.NewWindRose(cfg)
+
+	a.Add(3, 0)
+	b.Add(3, 0)
+	b.Add(8, 90)
+	b.Add(0, 0)
+
+	if err := a.Merge(b); err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+	counts, calm := a.Counts()
+	if diff := cmp.Diff([][]int{{2, 0}, {0, 1}, {0, 0}, {0, 0}}, counts); diff != "" || calm != 1 {
+		t.Errorf("merged counts mismatch (-want +got):\n%s\ncalm = %d, want 1", diff, calm)
+	}
+
+	if err := a.Merge(weatherflow.NewWindRose(weatherflow.WindRoseConfig{Sectors: 8})); err == nil {
+		t.Error("Merge with different sectors: expected an error")
+	}
+	if err := a.Merge(a); err == nil {
+		t.Error("Merge with itself: expected an error")
+	}
+}
+
+func TestWindRoseExport(t *testing.T) {
+	rose := weatherflow.NewWindRose(weatherflow.WindRoseConfig{Sectors: 4, SpeedBins: []float64{0, 5}})
+	rose.Add(3, 0)
+	rose.Add(3, 10)
+	rose.Add(8, 90)
+	rose.Add(0, 0)
+
+	var buf bytes.Buffer
+	if err := rose.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	wantCSV := `direction,0.5-5,5+,total
+N,50.00,0.00,50.00
+E,0.00,25.00,25.00
+S,0.00,0.00,0.00
+W,0.00,0.00,0.00
+calm,0.00,0.00,25.00
+`
+	if diff := cmp.Diff(wantCSV, buf.String()); diff != "" {
+		t.Errorf("CSV mismatch (-want +got):\n%s", diff)
+	}
+
+	data, err := json.Marshal(rose)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var restored weatherflow.WindRose
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	gotCounts, gotCalm := restored.Counts()
+	wantCounts, wantCalm := rose.Counts()
+	if diff := cmp.Diff(wantCounts, gotCounts); diff != "" || gotCalm != wantCalm {
+		t.Errorf("JSON round trip mismatch (-want +got):\n%s", diff)
+	}
+	if err := json.Unmarshal([]byte(`{"sectors":4,"speed_bins":[0],"counts":[[1]]}`), &restored); err == nil {
+		t.Error("Unmarshal of inconsistent rose: expected an error")
+	}
+
+	buf.Reset()
+	if err := rose.WriteSVG(&buf, 400); err != nil {
+		t.Fatalf("WriteSVG: %v", err)
+	}
+	svg := buf.String()
+	if n := strings.Count(svg, "<path "); n != 2 {
+		t.Errorf("SVG has %d wedges, want 2", n)
+	}
+	if !strings.Contains(svg, "calm 25.0%") {
+		t.Error("SVG is missing the calm percentage")
+	}
+	dec := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		if _, err := dec.Token(); err != nil {
+			if err != io.EOF {
+				t.Errorf("SVG isn't well-formed XML: %v", err)
+			}
+			break
+		}
+	}
+}